@@ -0,0 +1,102 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// DefCheckpointExt define the extension that will be appended to
+	// input file name, to create the checkpoint file.
+	DefCheckpointExt = ".checkpoint"
+)
+
+//
+// Checkpoint contain the position and counters of reader after each Read.
+// Checkpoint can be used to resume reading the input file from the last
+// position, without reading the previous rows again.
+//
+type Checkpoint struct {
+	// Offset is the byte offset in input file, where the next line will
+	// be read.
+	Offset int64 `json:"Offset"`
+	// Line is the number of physical line that has been read from input
+	// file, including the skipped lines.
+	Line int `json:"Line"`
+	// Rows is the number of rows that has been read and pushed to
+	// dataset.
+	Rows int `json:"Rows"`
+	// Rejected is the number of line that has been rejected.
+	Rejected int `json:"Rejected"`
+	// Filtered is the number of rows that does not match with reader
+	// Filter.
+	Filtered int `json:"Filtered"`
+	// Records is the number of records that has been read from input
+	// file, including the rejected and filtered records, before its
+	// transformed into rows.
+	Records int `json:"Records"`
+	// Sum is the sum of trailer SumColumn values in rows that has been
+	// read.
+	Sum float64 `json:"Sum"`
+}
+
+//
+// getCheckpoint return the checkpoint of reader, or new checkpoint if reader
+// does not implement CheckpointReaderInterface.
+//
+func getCheckpoint(reader ReaderInterface) *Checkpoint {
+	if v, ok := reader.(CheckpointReaderInterface); ok {
+		return v.GetCheckpoint()
+	}
+	return &Checkpoint{}
+}
+
+//
+// LoadCheckpoint read checkpoint from file.
+// If file is not exist, it will return nil checkpoint without error.
+//
+func LoadCheckpoint(file string) (cp *Checkpoint, e error) {
+	b, e := ioutil.ReadFile(file)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return nil, nil
+		}
+		return nil, e
+	}
+
+	cp = &Checkpoint{}
+
+	e = json.Unmarshal(b, cp)
+	if e != nil {
+		return nil, e
+	}
+
+	return cp, nil
+}
+
+//
+// Save checkpoint to file.
+// The checkpoint is written to temporary file first and then renamed to
+// `file`, so the previous checkpoint is not corrupted if the process died
+// in the middle of writing.
+//
+func (cp *Checkpoint) Save(file string) (e error) {
+	b, e := json.Marshal(cp)
+	if e != nil {
+		return e
+	}
+
+	tmp := file + ".tmp"
+
+	e = ioutil.WriteFile(tmp, b, 0600)
+	if e != nil {
+		return e
+	}
+
+	return os.Rename(tmp, file)
+}
@@ -11,11 +11,29 @@ import (
 	"github.com/shuLhan/tabula"
 	"io"
 	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"runtime/debug"
 	"testing"
 )
 
+//
+// writeTempFile create temporary directory, which is removed when the test
+// finished, and write the content to file `name` inside it.
+// It will return the directory and the path to file.
+//
+func writeTempFile(t *testing.T, name, content string) (dir, path string) {
+	dir = t.TempDir()
+	path = filepath.Join(dir, name)
+
+	e := ioutil.WriteFile(path, []byte(content), 0600)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return dir, path
+}
+
 func assert(t *testing.T, exp, got interface{}, equal bool) {
 	if reflect.DeepEqual(exp, got) != equal {
 		debug.PrintStack()
@@ -12,9 +12,10 @@
 package dsv
 
 import (
+	"context"
 	"errors"
-	"os"
-	"strconv"
+	"github.com/shuLhan/tabula"
+	"io"
 )
 
 const (
@@ -29,6 +30,9 @@ const (
 	DefDatasetMode = DatasetModeROWS
 	// DefEOL default end-of-line
 	DefEOL = '\n'
+	// DefFollowInterval define the default time, in milliseconds, to wait
+	// for new data when reader is in Follow mode.
+	DefFollowInterval = 1000
 )
 
 var (
@@ -46,8 +50,37 @@ var (
 	// ErrNilReader define an error when Reader object is nil when passed
 	// to Write function.
 	ErrNilReader = errors.New("dsv: Reader object is nil")
+	// ErrInvalidOffset define an error when seeking input to offset
+	// outside of input size.
+	ErrInvalidOffset = errors.New("dsv: Invalid input offset")
+	// ErrIdle define an error when reader is in Follow mode and no new
+	// line is available in input file.
+	ErrIdle = errors.New("dsv: No new line in input")
+	// ErrLineTooLong define an error when the length of line is more than
+	// MaxLineBytes.
+	ErrLineTooLong = errors.New("dsv: Line too long")
+	// ErrRecordTooLong define an error when the number of lines in one
+	// record is more than MaxRecordLines.
+	ErrRecordTooLong = errors.New("dsv: Record has too many lines")
+	// ErrNoLayout define an error when line does not match with any
+	// layout and reader does not have InputMetadata.
+	ErrNoLayout = errors.New("dsv: No layout match with line")
+	// ErrTransformColumns define an error when output transformers is
+	// applied to dataset in columns mode.
+	ErrTransformColumns = errors.New("dsv: Output transformers can not be applied to dataset in columns mode")
+	// ErrProfileColumns define an error when profiler is used on reader
+	// with dataset in columns mode.
+	ErrProfileColumns = errors.New("dsv: Profiler can not be used on dataset in columns mode")
+	// ErrProfileRowShape define an error when the number of columns in
+	// row that is profiled is not equal with the number of columns in
+	// reader input metadata, for example when the row is changed by
+	// reader transformers.
+	ErrProfileRowShape = errors.New("dsv: Number of columns in row does not match with input metadata")
 
 	// DEBUG imported from environment DSV_DEBUG to debug the library.
+	//
+	// Deprecated: DEBUG is not used anymore. Set Logger in Reader or
+	// Writer to receive the debug events.
 	DEBUG = 0
 )
 
@@ -57,14 +90,10 @@ var (
 type ReadWriter struct {
 	Reader
 	Writer
-}
-
-func init() {
-	var e error
-	DEBUG, e = strconv.Atoi(os.Getenv("DSV_DEBUG"))
-	if e != nil {
-		DEBUG = 0
-	}
+	// OutputTransformers define the chain of row transformers that is
+	// applied to each row in reader dataset before its written to output.
+	// Row that failed to be transformed is written to rejected file.
+	OutputTransformers RowTransformers `json:"-"`
 }
 
 //
@@ -94,6 +123,199 @@ func (dsv *ReadWriter) SetConfigPath(dir string) {
 	dsv.Writer.SetConfigPath(dir)
 }
 
+//
+// WriteDataset write all rows in reader dataset to output file, after
+// applying the OutputTransformers to each row.
+// Row that failed to be transformed is written to rejected file, using the
+// format in reader InputMetadata.
+// Return n for number of row written, and e if error happened.
+//
+func (dsv *ReadWriter) WriteDataset() (n int, e error) {
+	if len(dsv.OutputTransformers) == 0 {
+		return dsv.Writer.Write(&dsv.Reader)
+	}
+	if nil == dsv.Writer.fWriter {
+		return 0, ErrNotOpen
+	}
+
+	ds := dsv.Reader.GetDataset().(tabula.DatasetInterface)
+	if ds.GetMode() == tabula.DatasetModeColumns {
+		return 0, ErrTransformColumns
+	}
+
+	md := dsv.Reader.GetInputMetadata()
+	rowMd := rowMetadata(md)
+
+	for _, row := range *ds.GetDataAsRows() {
+		rows, eTransform := dsv.OutputTransformers.TransformRow(row, rowMd)
+		if eTransform != nil {
+			dsv.GetLogger().Warn("row rejected", "error", eTransform)
+
+			e = dsv.rejectRow(row)
+			if e != nil {
+				return n, e
+			}
+			continue
+		}
+
+		for _, out := range rows {
+			e = dsv.Writer.WriteRow(out, md)
+			if e != nil {
+				return n, e
+			}
+			n++
+		}
+	}
+
+	e = dsv.Writer.Flush()
+	if e != nil {
+		return n, e
+	}
+
+	return n, dsv.Reader.Flush()
+}
+
+//
+// Run read all rows from input and write them to output, in batch of reader
+// MaxRows, until the end of input file or until `ctx` is canceled.
+// If MaxRows is less or equal to zero, the DefaultMaxRows is used as the
+// batch size, so only one batch is kept in memory.
+//
+// Each row is filtered and transformed by the reader Filter and
+// Transformers, and by the OutputTransformers before its written.
+//
+// The context is checked before reading each batch. In Follow mode, when
+// the context is canceled while waiting for new line, the reader stop
+// following the input file, as if StopFollow is called, and Run return the
+// context error after writing the rows that has been read.
+//
+// The reader progress include the number of rows that has been written.
+//
+// If reader PersistCheckpoint is true, the reader checkpoint is saved after
+// each batch has been written and flushed to output file. If writing failed,
+// the checkpoint is not saved, so the rows in the failed batch will be read
+// again when the input is resumed.
+//
+// Return the statistics of this run, and error if happened.
+//
+func (dsv *ReadWriter) Run(ctx context.Context) (stats *Stats, e error) {
+	dsv.Reader.ReportProgress(true)
+	start := dsv.Stats()
+
+	// Include the rows written in the reader progress.
+	orig := dsv.Reader.Progress
+	progress := orig
+	if progress == nil {
+		progress = dsv.Reader.logProgress
+	}
+	dsv.Reader.Progress = func(stats Stats) {
+		stats.RowsWritten = dsv.Writer.GetRowsWritten()
+		progress(stats)
+	}
+	defer func() {
+		dsv.Reader.Progress = orig
+	}()
+
+	maxRows := dsv.Reader.GetMaxRows()
+	if maxRows <= 0 {
+		dsv.Reader.SetMaxRows(DefaultMaxRows)
+		defer dsv.Reader.SetMaxRows(maxRows)
+	}
+
+	if dsv.Reader.Follow {
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				dsv.Reader.StopFollow()
+			case <-done:
+			}
+		}()
+	}
+
+	for {
+		e = ctx.Err()
+		if e != nil {
+			break
+		}
+
+		_, eRead := Read(&dsv.Reader)
+
+		_, eWrite := dsv.WriteDataset()
+		if eWrite == nil {
+			eWrite = dsv.Writer.Flush()
+		}
+		if eWrite != nil {
+			e = eWrite
+			break
+		}
+
+		// Save the checkpoint only after the rows has been written
+		// to output file.
+		e = dsv.Reader.SaveCheckpoint()
+		if e != nil {
+			break
+		}
+		if eRead == io.EOF {
+			// Reader may stop following the input because the
+			// context is canceled.
+			e = ctx.Err()
+			break
+		}
+		if eRead != nil {
+			e = eRead
+			break
+		}
+	}
+
+	dsv.Reader.ReportProgress(true)
+
+	end := dsv.Stats().since(start)
+
+	return &end, e
+}
+
+//
+// Stats return the statistics of reader, since the input file is opened, and
+// the number of rows that has been written.
+//
+func (dsv *ReadWriter) Stats() Stats {
+	stats := dsv.Reader.Stats()
+	stats.RowsWritten = dsv.Writer.GetRowsWritten()
+	return stats
+}
+
+//
+// rejectRow write the row to rejected file using the format in reader
+// InputMetadata.
+//
+func (dsv *ReadWriter) rejectRow(row *tabula.Row) (e error) {
+	v := []byte{}
+	x := 0
+
+	for i := range dsv.Reader.InputMetadata {
+		md := &dsv.Reader.InputMetadata[i]
+		if md.GetSkip() {
+			continue
+		}
+		if x >= row.Len() {
+			break
+		}
+
+		rec := (*row)[x]
+		v = appendField(v, rec.Bytes(), md, rec.Type() == tabula.TString)
+		x++
+	}
+
+	v = append(v, DefEOL)
+
+	_, e = dsv.Reader.Reject(v)
+
+	return e
+}
+
 //
 // Close reader and writer.
 //
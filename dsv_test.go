@@ -5,7 +5,13 @@
 package dsv_test
 
 import (
+	"context"
+	"errors"
 	"github.com/shuLhan/dsv"
+	"github.com/shuLhan/tabula"
+	"io"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
@@ -95,3 +101,253 @@ func TestSimpleMerge(t *testing.T) {
 
 	assertFile(t, fexp, fout, true)
 }
+
+func TestReadWriterOutputTransformers(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "1,a\n2,b\n3,c\n")
+	output := filepath.Join(dir, "output.dat")
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	rw := &dsv.ReadWriter{}
+
+	rw.Reader.Input = input
+	rw.Reader.Rejected = rejected
+	rw.Reader.MaxRows = -1
+	rw.Reader.InputMetadata = []dsv.Metadata{{
+		Name:      "id",
+		Type:      "integer",
+		Separator: ",",
+	}, {
+		Name: "name",
+	}}
+
+	rw.Writer.OutputMetadata = []dsv.Metadata{{
+		Name:      "name",
+		Separator: ";",
+	}, {
+		Name: "id",
+	}}
+
+	// reject id 2 and duplicate id 3.
+	rw.OutputTransformers = dsv.RowTransformers{
+		dsv.RowTransformerFunc(func(row *tabula.Row,
+			md []dsv.MetadataInterface,
+		) (
+			[]*tabula.Row, error,
+		) {
+			switch (*row)[0].Integer() {
+			case 2:
+				return nil, errors.New("invalid id 2")
+			case 3:
+				return []*tabula.Row{row, row}, nil
+			}
+			return []*tabula.Row{row}, nil
+		}),
+	}
+
+	e := rw.Reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = rw.Writer.OpenOutput(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(&rw.Reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	n, e := rw.WriteDataset()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, 3, n, true)
+
+	got, e := ioutil.ReadFile(rejected)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "2,b\n", string(got), true)
+
+	e = rw.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	got, e = ioutil.ReadFile(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "a;1\nc;3\nc;3\n", string(got), true)
+}
+
+func TestConvert(t *testing.T) {
+	stats, e := dsv.Convert("testdata/config_simpleread.dsv")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	exp := &dsv.Stats{
+		Bytes:        491,
+		Lines:        14,
+		RowsRead:     10,
+		RowsWritten:  10,
+		RowsRejected: 3,
+	}
+
+	stats.Elapsed = 0
+
+	assert(t, exp, stats, true)
+
+	assertFile(t, "testdata/expected.dat", "testdata/output.dat", true)
+}
+
+func TestReadWriterRun(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"1,a\n2,b\nx,c\n4,d\n5,e\n6,f\n")
+	output := filepath.Join(dir, "output.dat")
+
+	rw := &dsv.ReadWriter{}
+
+	rw.Reader.Input = input
+	rw.Reader.Rejected = filepath.Join(dir, "rejected.dat")
+	rw.Reader.MaxRows = 2
+	rw.Reader.Filter = "id != 5"
+	rw.Reader.InputMetadata = []dsv.Metadata{{
+		Name:      "id",
+		Type:      "integer",
+		Separator: ",",
+	}, {
+		Name: "name",
+	}}
+
+	rw.Writer.OutputMetadata = []dsv.Metadata{{
+		Name:      "name",
+		Separator: ",",
+	}, {
+		Name: "id",
+	}}
+
+	e := rw.Reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = rw.Writer.OpenOutput(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Canceled context does not read any rows.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stats, e := rw.Run(ctx)
+
+	assert(t, context.Canceled, e, true)
+
+	stats.Elapsed = 0
+
+	assert(t, &dsv.Stats{}, stats, true)
+
+	stats, e = rw.Run(context.Background())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	exp := &dsv.Stats{
+		Bytes:        24,
+		Lines:        6,
+		RowsRead:     4,
+		RowsWritten:  4,
+		RowsFiltered: 1,
+		RowsRejected: 1,
+	}
+
+	stats.Elapsed = 0
+
+	assert(t, exp, stats, true)
+
+	e = rw.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	got, e := ioutil.ReadFile(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "a,1\nb,2\nd,4\nf,6\n", string(got), true)
+}
+
+//
+// TestReadWriterRunCheckpoint test that Run save the checkpoint only after
+// the batch of rows has been written.
+//
+func TestReadWriterRunCheckpoint(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "1,a\n2,b\n3,c\n4,d\n")
+
+	rw := &dsv.ReadWriter{}
+
+	rw.Reader.Input = input
+	rw.Reader.Rejected = filepath.Join(dir, "rejected.dat")
+	rw.Reader.MaxRows = 2
+	rw.Reader.PersistCheckpoint = true
+	rw.Reader.InputMetadata = []dsv.Metadata{{
+		Name:      "id",
+		Type:      "integer",
+		Separator: ",",
+	}, {
+		Name: "name",
+	}}
+
+	// Writing the last row failed with division by zero.
+	rw.Writer.OutputMetadata = []dsv.Metadata{{
+		Name:      "name",
+		Separator: ",",
+	}, {
+		Name: "x",
+		Type: "integer",
+		Expr: "10 / (id - 4)",
+	}}
+
+	e := rw.Reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = rw.Writer.OpenOutput(filepath.Join(dir, "output.dat"))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = rw.Run(context.Background())
+	if e == nil {
+		t.Fatal("expecting error on writing the last row")
+	}
+
+	e = rw.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cp, e := dsv.LoadCheckpoint(rw.Reader.GetCheckpointFile())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	exp := &dsv.Checkpoint{
+		Offset:  8,
+		Line:    2,
+		Rows:    2,
+		Records: 2,
+	}
+
+	assert(t, exp, cp, true)
+}
@@ -5,6 +5,7 @@
 package dsv
 
 import (
+	"context"
 	"io"
 )
 
@@ -35,6 +36,28 @@ func SimpleRead(fcfg string, dataset interface{}) (
 	return
 }
 
+//
+// Convert provide a shortcut to read all rows from input file and write them
+// to output file, using the reader and writer configuration in file `fcfg`.
+// Return the counters of rows that has been processed, and error if
+// happened.
+//
+func Convert(fcfg string) (stats *Stats, e error) {
+	rw, e := New(fcfg, nil)
+	if e != nil {
+		return nil, e
+	}
+
+	stats, e = rw.Run(context.Background())
+
+	eClose := rw.Close()
+	if e == nil {
+		e = eClose
+	}
+
+	return stats, e
+}
+
 //
 // SimpleWrite provide a shortcut to write data from reader using output metadata
 // format and output file defined in file `fcfg`.
@@ -0,0 +1,927 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"fmt"
+	"github.com/shuLhan/tabula"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//
+// expr contain the compiled expression over the columns in row.
+//
+// The expression use the following grammar, from the lowest to the highest
+// precedence,
+//
+//	or      = and *( "||" and )
+//	and     = compare *( "&&" compare )
+//	compare = add [ ( "==" / "!=" / "<" / "<=" / ">" / ">=" ) add ]
+//	add     = mul *( ( "+" / "-" ) mul )
+//	mul     = unary *( ( "*" / "/" / "%" ) unary )
+//	unary   = ( "!" / "-" ) unary / primary
+//	primary = number / string / "true" / "false" / column /
+//	          function "(" [ or *( "," or ) ] ")" / "(" or ")"
+//
+// String is quoted with double or single quote.
+// Column is referenced by its name, or quoted with backtick if the name
+// contain characters other than letter, digit, underscore, and dot.
+// The value of column is integer, real, or string, based on its type in
+// metadata.
+//
+// Operator "+" concatenate the operands if one of them is string, "/" always
+// return real number, and comparison between string and number is done as
+// number if the string is a valid number.
+//
+type expr struct {
+	src  string
+	root exprNode
+	// cols contain all column nodes in expression tree.
+	cols []*exprColumn
+	// mds is the list of metadata where the columns is bound to.
+	mds []MetadataInterface
+}
+
+//
+// exprNode is the interface for node in expression tree.
+// The value of column is taken from `row`.
+//
+type exprNode interface {
+	eval(row *tabula.Row) (interface{}, error)
+}
+
+type exprLiteral struct {
+	v interface{}
+}
+
+type exprColumn struct {
+	name string
+	// idx is the index of column in row, or -1 if column is not bound
+	// to metadata yet.
+	idx int
+}
+
+type exprUnary struct {
+	op string
+	x  exprNode
+}
+
+type exprBinary struct {
+	op string
+	x  exprNode
+	y  exprNode
+}
+
+type exprCall struct {
+	name string
+	fn   exprFunc
+	args []exprNode
+}
+
+//
+// exprFunc is the builtin function that can be called in expression.
+//
+type exprFunc func(args []interface{}) (interface{}, error)
+
+//
+// exprFuncs contain list of builtin functions, by name.
+//
+var exprFuncs = map[string]exprFunc{
+	"concat":   exprConcat,
+	"contains": exprContains,
+	"int":      exprInt,
+	"len":      exprLen,
+	"lower":    exprLower,
+	"real":     exprReal,
+	"substr":   exprSubstr,
+	"trim":     exprTrim,
+	"upper":    exprUpper,
+}
+
+const (
+	exprTokEOF = iota
+	exprTokNumber
+	exprTokString
+	exprTokIdent
+	exprTokOp
+)
+
+type exprToken struct {
+	kind int
+	s    string
+	v    interface{}
+	pos  int
+}
+
+//
+// exprOps contain list of operators, where the operator with two characters
+// must be placed before the one character.
+//
+var exprOps = []string{
+	"||", "&&", "==", "!=", "<=", ">=",
+	"<", ">", "+", "-", "*", "/", "%", "!", "(", ")", ",",
+}
+
+//
+// compileExpr parse the expression `src` into expression tree.
+//
+func compileExpr(src string) (x *expr, e error) {
+	toks, e := lexExpr(src)
+	if e != nil {
+		return nil, e
+	}
+
+	ps := &exprParser{
+		src:  src,
+		toks: toks,
+	}
+
+	root, e := ps.parseOr()
+	if e != nil {
+		return nil, e
+	}
+
+	if tok := ps.peek(); tok.kind != exprTokEOF {
+		return nil, ps.errorf(tok, "unexpected %q", tok.s)
+	}
+
+	x = &expr{src: src, root: root}
+
+	exprColumns(root, &x.cols)
+
+	return x, nil
+}
+
+//
+// lexExpr split the expression into tokens.
+//
+func lexExpr(src string) (toks []exprToken, e error) {
+	p := 0
+
+	for p < len(src) {
+		r, size := utf8.DecodeRuneInString(src[p:])
+
+		switch {
+		case unicode.IsSpace(r):
+			p += size
+			continue
+
+		case r >= '0' && r <= '9', r == '.' && p+1 < len(src) &&
+			src[p+1] >= '0' && src[p+1] <= '9':
+			start := p
+			for p < len(src) && (src[p] == '.' ||
+				(src[p] >= '0' && src[p] <= '9')) {
+				p++
+			}
+
+			tok := exprToken{
+				kind: exprTokNumber,
+				s:    src[start:p],
+				pos:  start,
+			}
+
+			if strings.IndexByte(tok.s, '.') >= 0 {
+				tok.v, e = strconv.ParseFloat(tok.s, 64)
+			} else {
+				tok.v, e = strconv.ParseInt(tok.s, 10, 64)
+			}
+			if e != nil {
+				return nil, fmt.Errorf("dsv: Invalid expression %q: "+
+					"invalid number %q", src, tok.s)
+			}
+
+			toks = append(toks, tok)
+			continue
+
+		case r == '"' || r == '\'' || r == '`':
+			start := p
+			v := []byte{}
+
+			for p++; p < len(src) && rune(src[p]) != r; p++ {
+				if src[p] == '\\' && r != '`' && p+1 < len(src) {
+					p++
+				}
+				v = append(v, src[p])
+			}
+			if p >= len(src) {
+				return nil, fmt.Errorf("dsv: Invalid expression %q: "+
+					"missing closing quote %c", src, r)
+			}
+			p++
+
+			kind := exprTokString
+			if r == '`' {
+				kind = exprTokIdent
+			}
+
+			toks = append(toks, exprToken{
+				kind: kind,
+				s:    src[start:p],
+				v:    string(v),
+				pos:  start,
+			})
+			continue
+
+		case r == '_' || unicode.IsLetter(r):
+			start := p
+			for p < len(src) {
+				r, size = utf8.DecodeRuneInString(src[p:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) &&
+					!unicode.IsDigit(r) {
+					break
+				}
+				p += size
+			}
+
+			toks = append(toks, exprToken{
+				kind: exprTokIdent,
+				s:    src[start:p],
+				v:    src[start:p],
+				pos:  start,
+			})
+			continue
+		}
+
+		isOp := false
+		for _, op := range exprOps {
+			if strings.HasPrefix(src[p:], op) {
+				toks = append(toks, exprToken{
+					kind: exprTokOp,
+					s:    op,
+					pos:  p,
+				})
+				p += len(op)
+				isOp = true
+				break
+			}
+		}
+		if !isOp {
+			return nil, fmt.Errorf("dsv: Invalid expression %q: "+
+				"unknown character %q at %d", src, r, p)
+		}
+	}
+
+	toks = append(toks, exprToken{kind: exprTokEOF, pos: len(src)})
+
+	return toks, nil
+}
+
+//
+// exprParser parse list of tokens into expression tree.
+//
+type exprParser struct {
+	src  string
+	toks []exprToken
+	p    int
+}
+
+func (ps *exprParser) peek() exprToken {
+	return ps.toks[ps.p]
+}
+
+func (ps *exprParser) next() exprToken {
+	tok := ps.toks[ps.p]
+	if tok.kind != exprTokEOF {
+		ps.p++
+	}
+	return tok
+}
+
+//
+// matchOp return the operator and move to the next token if the current
+// token is one of `ops`.
+//
+func (ps *exprParser) matchOp(ops ...string) (string, bool) {
+	tok := ps.peek()
+	if tok.kind != exprTokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.s == op {
+			ps.p++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (ps *exprParser) errorf(tok exprToken, format string,
+	args ...interface{},
+) error {
+	if tok.kind == exprTokEOF {
+		return fmt.Errorf("dsv: Invalid expression %q: unexpected end",
+			ps.src)
+	}
+	return fmt.Errorf("dsv: Invalid expression %q: %s at %d", ps.src,
+		fmt.Sprintf(format, args...), tok.pos)
+}
+
+//
+// parseBinary parse the left-associative binary operators `ops`, where each
+// operand is parsed using `operand`.
+//
+func (ps *exprParser) parseBinary(operand func() (exprNode, error),
+	ops ...string,
+) (x exprNode, e error) {
+	x, e = operand()
+	if e != nil {
+		return nil, e
+	}
+
+	for {
+		op, ok := ps.matchOp(ops...)
+		if !ok {
+			return x, nil
+		}
+
+		y, e := operand()
+		if e != nil {
+			return nil, e
+		}
+
+		x = &exprBinary{op: op, x: x, y: y}
+	}
+}
+
+func (ps *exprParser) parseOr() (exprNode, error) {
+	return ps.parseBinary(ps.parseAnd, "||")
+}
+
+func (ps *exprParser) parseAnd() (exprNode, error) {
+	return ps.parseBinary(ps.parseCompare, "&&")
+}
+
+func (ps *exprParser) parseCompare() (x exprNode, e error) {
+	x, e = ps.parseAdd()
+	if e != nil {
+		return nil, e
+	}
+
+	op, ok := ps.matchOp("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return x, nil
+	}
+
+	y, e := ps.parseAdd()
+	if e != nil {
+		return nil, e
+	}
+
+	return &exprBinary{op: op, x: x, y: y}, nil
+}
+
+func (ps *exprParser) parseAdd() (exprNode, error) {
+	return ps.parseBinary(ps.parseMul, "+", "-")
+}
+
+func (ps *exprParser) parseMul() (exprNode, error) {
+	return ps.parseBinary(ps.parseUnary, "*", "/", "%")
+}
+
+func (ps *exprParser) parseUnary() (exprNode, error) {
+	op, ok := ps.matchOp("!", "-")
+	if !ok {
+		return ps.parsePrimary()
+	}
+
+	x, e := ps.parseUnary()
+	if e != nil {
+		return nil, e
+	}
+
+	return &exprUnary{op: op, x: x}, nil
+}
+
+func (ps *exprParser) parsePrimary() (x exprNode, e error) {
+	tok := ps.next()
+
+	switch tok.kind {
+	case exprTokNumber, exprTokString:
+		return &exprLiteral{v: tok.v}, nil
+
+	case exprTokIdent:
+		name := tok.v.(string)
+		isQuoted := tok.s[0] == '`'
+
+		if !isQuoted && (name == "true" || name == "false") {
+			return &exprLiteral{v: name == "true"}, nil
+		}
+		if next := ps.peek(); isQuoted || next.kind != exprTokOp ||
+			next.s != "(" {
+			return &exprColumn{name: name, idx: -1}, nil
+		}
+
+		ps.next()
+
+		fn, ok := exprFuncs[name]
+		if !ok {
+			return nil, ps.errorf(tok, "unknown function %q", name)
+		}
+
+		call := &exprCall{name: name, fn: fn}
+
+		if _, ok = ps.matchOp(")"); ok {
+			return call, nil
+		}
+
+		for {
+			arg, e := ps.parseOr()
+			if e != nil {
+				return nil, e
+			}
+
+			call.args = append(call.args, arg)
+
+			if _, ok = ps.matchOp(","); ok {
+				continue
+			}
+			if _, ok = ps.matchOp(")"); ok {
+				return call, nil
+			}
+
+			return nil, ps.errorf(ps.peek(), "missing \")\"")
+		}
+
+	case exprTokOp:
+		if tok.s == "(" {
+			x, e = ps.parseOr()
+			if e != nil {
+				return nil, e
+			}
+			if _, ok := ps.matchOp(")"); !ok {
+				return nil, ps.errorf(ps.peek(), "missing \")\"")
+			}
+			return x, nil
+		}
+	}
+
+	return nil, ps.errorf(tok, "unexpected %q", tok.s)
+}
+
+//
+// eval evaluate the expression using the column values in `row`.
+//
+func (x *expr) eval(row *tabula.Row) (v interface{}, e error) {
+	v, e = x.root.eval(row)
+	if e != nil {
+		return nil, fmt.Errorf("dsv: Expression %q: %s", x.src, e)
+	}
+	return v, nil
+}
+
+//
+// evalRow evaluate the expression using the values in row, where each column
+// is found by name in metadata `mds`.
+// The columns is bound to their index in row only if `mds` is not the
+// metadata where the columns has been bound to.
+//
+func (x *expr) evalRow(row *tabula.Row, mds []MetadataInterface) (
+	interface{}, error,
+) {
+	if !x.isBound(mds) {
+		e := x.bind(mds)
+		if e != nil {
+			return nil, e
+		}
+	}
+	return x.eval(row)
+}
+
+//
+// isBound return true if the columns has been bound to metadata `mds`.
+//
+func (x *expr) isBound(mds []MetadataInterface) bool {
+	if x.mds == nil || len(x.mds) != len(mds) {
+		return false
+	}
+	for i := range mds {
+		if x.mds[i] != mds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//
+// bind find each column in expression by name in metadata `mds`, and save
+// their index in row.
+// It will return an error if one of column is not found in metadata, or the
+// column is skipped.
+//
+func (x *expr) bind(mds []MetadataInterface) error {
+	x.mds = nil
+
+	for _, col := range x.cols {
+		idx, md := FindMetadata(&Metadata{Name: col.name}, mds)
+		if md == nil || md.GetSkip() {
+			return fmt.Errorf("dsv: Expression %q: unknown column %q",
+				x.src, col.name)
+		}
+		col.idx = idx
+	}
+
+	x.mds = append(make([]MetadataInterface, 0, len(mds)), mds...)
+
+	return nil
+}
+
+//
+// exprColumns append all column nodes in expression tree to `cols`.
+//
+func exprColumns(node exprNode, cols *[]*exprColumn) {
+	switch x := node.(type) {
+	case *exprColumn:
+		*cols = append(*cols, x)
+	case *exprUnary:
+		exprColumns(x.x, cols)
+	case *exprBinary:
+		exprColumns(x.x, cols)
+		exprColumns(x.y, cols)
+	case *exprCall:
+		for _, arg := range x.args {
+			exprColumns(arg, cols)
+		}
+	}
+}
+
+//
+// recordValue return the value of record as int64, float64, or string.
+//
+func recordValue(rec *tabula.Record) interface{} {
+	switch rec.Type() {
+	case tabula.TInteger:
+		return rec.Integer()
+	case tabula.TReal:
+		return rec.Float()
+	}
+	return rec.String()
+}
+
+//
+// valueRecord convert the value of expression to record.
+//
+func valueRecord(v interface{}) *tabula.Record {
+	switch x := v.(type) {
+	case int64:
+		return tabula.NewRecordInt(x)
+	case float64:
+		return tabula.NewRecordReal(x)
+	}
+	return tabula.NewRecordString(exprString(v))
+}
+
+func (x *exprLiteral) eval(row *tabula.Row) (interface{}, error) {
+	return x.v, nil
+}
+
+func (x *exprColumn) eval(row *tabula.Row) (interface{}, error) {
+	if x.idx < 0 || x.idx >= row.Len() {
+		return nil, fmt.Errorf("unknown column %q", x.name)
+	}
+	return recordValue((*row)[x.idx]), nil
+}
+
+func (x *exprUnary) eval(row *tabula.Row) (interface{}, error) {
+	v, e := x.x.eval(row)
+	if e != nil {
+		return nil, e
+	}
+
+	if x.op == "!" {
+		return !exprTruth(v), nil
+	}
+
+	switch n := v.(type) {
+	case int64:
+		return -n, nil
+	case float64:
+		return -n, nil
+	}
+
+	f, ok := exprFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("invalid operand %q for \"-\"",
+			exprString(v))
+	}
+	return -f, nil
+}
+
+func (x *exprBinary) eval(row *tabula.Row) (interface{}, error) {
+	a, e := x.x.eval(row)
+	if e != nil {
+		return nil, e
+	}
+
+	// Logical operators is short-circuit.
+	switch x.op {
+	case "||":
+		if exprTruth(a) {
+			return true, nil
+		}
+	case "&&":
+		if !exprTruth(a) {
+			return false, nil
+		}
+	}
+
+	b, e := x.y.eval(row)
+	if e != nil {
+		return nil, e
+	}
+
+	switch x.op {
+	case "||", "&&":
+		return exprTruth(b), nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		return exprCompare(x.op, a, b), nil
+	}
+
+	return exprArith(x.op, a, b)
+}
+
+func (x *exprCall) eval(row *tabula.Row) (interface{}, error) {
+	args := make([]interface{}, len(x.args))
+
+	for i, arg := range x.args {
+		v, e := arg.eval(row)
+		if e != nil {
+			return nil, e
+		}
+		args[i] = v
+	}
+
+	v, e := x.fn(args)
+	if e != nil {
+		return nil, fmt.Errorf("%s: %s", x.name, e)
+	}
+	return v, nil
+}
+
+//
+// exprTruth return the boolean value of v. Number is true if its not zero, and
+// string is true if its not empty.
+//
+func exprTruth(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case int64:
+		return x != 0
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return false
+}
+
+//
+// exprFloat convert the value to real number.
+//
+func exprFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case string:
+		f, e := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, e == nil
+	}
+	return 0, false
+}
+
+//
+// exprString convert the value to string.
+//
+func exprString(v interface{}) string {
+	switch x := v.(type) {
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case string:
+		return x
+	}
+	return ""
+}
+
+//
+// exprCompare compare two values. If both of them is string, they are
+// compared as string, otherwise they are compared as number if both can be
+// converted to number.
+//
+func exprCompare(op string, a, b interface{}) bool {
+	var cmp int
+
+	sa, aIsString := a.(string)
+	sb, bIsString := b.(string)
+	fa, aIsNum := exprFloat(a)
+	fb, bIsNum := exprFloat(b)
+
+	switch {
+	case aIsString && bIsString:
+		cmp = strings.Compare(sa, sb)
+	case aIsNum && bIsNum:
+		switch {
+		case fa < fb:
+			cmp = -1
+		case fa > fb:
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(exprString(a), exprString(b))
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+//
+// exprArith compute the arithmetic operation between two values.
+//
+func exprArith(op string, a, b interface{}) (interface{}, error) {
+	_, aIsString := a.(string)
+	_, bIsString := b.(string)
+
+	if op == "+" && (aIsString || bIsString) {
+		return exprString(a) + exprString(b), nil
+	}
+
+	ia, aIsInt := a.(int64)
+	ib, bIsInt := b.(int64)
+
+	if aIsInt && bIsInt {
+		switch op {
+		case "+":
+			return ia + ib, nil
+		case "-":
+			return ia - ib, nil
+		case "*":
+			return ia * ib, nil
+		case "%":
+			if ib == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return ia % ib, nil
+		}
+	}
+
+	fa, aIsNum := exprFloat(a)
+	fb, bIsNum := exprFloat(b)
+	if !aIsNum || !bIsNum {
+		return nil, fmt.Errorf("invalid operands %q %s %q",
+			exprString(a), op, exprString(b))
+	}
+
+	switch op {
+	case "+":
+		return fa + fb, nil
+	case "-":
+		return fa - fb, nil
+	case "*":
+		return fa * fb, nil
+	case "/":
+		if fb == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return fa / fb, nil
+	}
+
+	if fb == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	return math.Mod(fa, fb), nil
+}
+
+func exprNArgs(args []interface{}, min, max int) error {
+	if len(args) < min || len(args) > max {
+		return fmt.Errorf("invalid number of arguments %d", len(args))
+	}
+	return nil
+}
+
+func exprConcat(args []interface{}) (interface{}, error) {
+	var s string
+	for _, arg := range args {
+		s += exprString(arg)
+	}
+	return s, nil
+}
+
+func exprContains(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 2, 2); e != nil {
+		return nil, e
+	}
+	return strings.Contains(exprString(args[0]), exprString(args[1])), nil
+}
+
+func exprInt(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 1, 1); e != nil {
+		return nil, e
+	}
+	if i, ok := args[0].(int64); ok {
+		return i, nil
+	}
+	f, ok := exprFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", exprString(args[0]))
+	}
+	return int64(f), nil
+}
+
+func exprLen(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 1, 1); e != nil {
+		return nil, e
+	}
+	return int64(utf8.RuneCountInString(exprString(args[0]))), nil
+}
+
+func exprLower(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 1, 1); e != nil {
+		return nil, e
+	}
+	return strings.ToLower(exprString(args[0])), nil
+}
+
+func exprReal(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 1, 1); e != nil {
+		return nil, e
+	}
+	f, ok := exprFloat(args[0])
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", exprString(args[0]))
+	}
+	return f, nil
+}
+
+//
+// exprSubstr return part of string in first argument, starting from
+// character index in second argument (start from 0), with maximum length in
+// the optional third argument.
+//
+func exprSubstr(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 2, 3); e != nil {
+		return nil, e
+	}
+
+	s := []rune(exprString(args[0]))
+
+	start, ok := args[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("invalid start %q", exprString(args[1]))
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > int64(len(s)) {
+		start = int64(len(s))
+	}
+
+	end := int64(len(s))
+	if len(args) == 3 {
+		n, ok := args[2].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid length %q",
+				exprString(args[2]))
+		}
+		if n >= 0 && n < end-start {
+			end = start + n
+		}
+	}
+
+	return string(s[start:end]), nil
+}
+
+func exprTrim(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 1, 1); e != nil {
+		return nil, e
+	}
+	return strings.TrimSpace(exprString(args[0])), nil
+}
+
+func exprUpper(args []interface{}) (interface{}, error) {
+	if e := exprNArgs(args, 1, 1); e != nil {
+		return nil, e
+	}
+	return strings.ToUpper(exprString(args[0])), nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"github.com/shuLhan/tabula"
+)
+
+//
+// MatchFilter return true if row, that is parsed using reader InputMetadata,
+// match with reader Filter, or if reader does not have Filter.
+// It will return an error if Filter is not valid or can not be evaluated on
+// row.
+//
+func (reader *Reader) MatchFilter(row *tabula.Row) (ok bool, e error) {
+	if reader.Filter == "" {
+		return true, nil
+	}
+
+	if reader.filter == nil || reader.filter.src != reader.Filter {
+		reader.filter, e = compileExpr(reader.Filter)
+		if e != nil {
+			return false, e
+		}
+	}
+
+	v, e := reader.filter.evalRow(row, reader.parsePlan().mds)
+	if e != nil {
+		return false, e
+	}
+
+	return exprTruth(v), nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+//
+// charIndex return the index of byte in `line` after skipping `n` characters
+// starting from index `p`. If line is shorter than that, it will return the
+// length of line.
+//
+func charIndex(line []byte, p, n int) int {
+	for ; n > 0 && p < len(line); n-- {
+		if line[p] < utf8.RuneSelf {
+			p++
+			continue
+		}
+		_, size := utf8.DecodeRune(line[p:])
+		p += size
+	}
+	return p
+}
+
+//
+// parsingFixedWidth cut the value of fixed width column from line.
+//
+// If column has start position, the value is cut from that position,
+// otherwise its cut from `startAt`. If line is shorter than the column width,
+// the value is cut until the end of line.
+//
+// The pad character is removed from the right side of value, or from the
+// left side if value is aligned to the right, using trimPad.
+//
+func parsingFixedWidth(f *fieldPlan, line []byte, startAt int) (
+	v []byte, p int,
+) {
+	p = startAt
+	if f.start > 0 {
+		p = charIndex(line, 0, f.start-1)
+	}
+
+	end := charIndex(line, p, f.width)
+	v = line[p:end:end]
+
+	v = trimPad(v, f.pad, f.alignRight)
+
+	return v, end
+}
+
+//
+// isDigit return true if byte `c` is an ASCII digit.
+//
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+//
+// trimPad remove the pad from the left side of value if `isLeft` is true,
+// otherwise from the right side.
+//
+// If pad is a digit, only the pad that is followed, or preceded, by other
+// digit is removed, and the pad at the left side is removed after the sign,
+// so "0000" with pad "0" become "0", "-0012" become "-12", and "000.5"
+// become "0.5".
+//
+func trimPad(v []byte, pad string, isLeft bool) []byte {
+	if len(pad) == 0 {
+		return v
+	}
+
+	padb := []byte(pad)
+	isNum := len(pad) == 1 && isDigit(pad[0])
+
+	if !isLeft {
+		end := len(v)
+		for bytes.HasSuffix(v[:end], padb) {
+			x := end - len(padb)
+			if isNum && (x == 0 || !isDigit(v[x-1])) {
+				break
+			}
+			end = x
+		}
+		return v[:end]
+	}
+
+	sign := 0
+	if isNum && len(v) > 0 && (v[0] == '-' || v[0] == '+') {
+		sign = 1
+	}
+
+	p := sign
+	for bytes.HasPrefix(v[p:], padb) {
+		x := p + len(padb)
+		if isNum && (x == len(v) || !isDigit(v[x])) {
+			break
+		}
+		p = x
+	}
+
+	if sign == 0 || p == sign {
+		return v[p:]
+	}
+
+	out := make([]byte, 0, 1+len(v)-p)
+	out = append(out, v[0])
+	out = append(out, v[p:]...)
+
+	return out
+}
+
+//
+// appendFixedWidth append the value `recV` to `v` using fixed width format in
+// metadata `md`.
+//
+// If metadata has start position, `v` is filled with space until the start
+// position. Value that is longer than width will be truncated.
+//
+func appendFixedWidth(v, recV []byte, md *Metadata) []byte {
+	start := md.GetStart()
+	if start > 0 {
+		for n := utf8.RuneCount(v); n < start-1; n++ {
+			v = append(v, ' ')
+		}
+	}
+
+	return append(v, padWidth(recV, md)...)
+}
+
+//
+// padWidth return the value `recV` that is truncated or padded up to the
+// width in metadata `md`, using the pad character and alignment.
+//
+func padWidth(recV []byte, md *Metadata) []byte {
+	width := md.GetWidth()
+	n := utf8.RuneCount(recV)
+	if n > width {
+		return recV[:charIndex(recV, 0, width)]
+	}
+
+	pad := bytes.Repeat([]byte(md.GetPad()), width-n)
+
+	if md.GetAlign() == AlignRight {
+		return append(pad, recV...)
+	}
+
+	out := make([]byte, 0, len(recV)+len(pad))
+	out = append(out, recV...)
+	out = append(out, pad...)
+
+	return out
+}
@@ -0,0 +1,165 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"time"
+)
+
+//
+// StopFollow stop waiting for new data in input file.
+// The current or the next ReadLine that reach the end of input file will
+// return io.EOF.
+// This method is safe to be called from another goroutine.
+//
+func (reader *Reader) StopFollow() {
+	select {
+	case reader.followStop <- true:
+	default:
+	}
+}
+
+//
+// readLineFollow read one complete line from input file, waiting for more
+// data when reaching end of file.
+//
+// The incomplete line at the end of file is kept until the rest of line is
+// written.
+// If `notifyIdle` is true, the first time reader reach end of file it will
+// return ErrIdle, so the caller can process the rows that has been read.
+//
+// After input file is truncated or rotated, the first Skip lines in input
+// file are skipped again.
+//
+func (reader *Reader) readLineFollow(notifyIdle bool) (line []byte, e error) {
+	for {
+		line, e = reader.readLineBuffered()
+		if e == nil || e == ErrLineTooLong {
+			reader.isIdle = false
+			if reader.followSkip > 0 {
+				reader.followSkip--
+				continue
+			}
+			return line, e
+		}
+		if e != io.EOF {
+			return nil, e
+		}
+		if reader.isStopped {
+			return nil, io.EOF
+		}
+		if notifyIdle && !reader.isIdle {
+			reader.isIdle = true
+			return nil, ErrIdle
+		}
+
+		line, e = reader.followWait()
+		if line != nil {
+			reader.isIdle = false
+			return line, e
+		}
+		if e != nil {
+			return nil, e
+		}
+	}
+}
+
+//
+// followWait wait for FollowInterval and then check if the input file has
+// been truncated or rotated.
+//
+// If input file has been rotated, the rest of old file is read first before
+// switching to the new file. The incomplete line at the end of old file is
+// returned as the last line of old file, along with ErrLineTooLong if its
+// longer than MaxLineBytes.
+//
+func (reader *Reader) followWait() (line []byte, e error) {
+	interval := time.Duration(reader.FollowInterval) * time.Millisecond
+
+	select {
+	case <-reader.followStop:
+		reader.isStopped = true
+		return nil, nil
+	case <-time.After(interval):
+	}
+
+	finfo, e := reader.fRead.Stat()
+	if e != nil {
+		return nil, e
+	}
+
+	// Input file has been truncated.
+	offset := reader.checkpoint.Offset + int64(reader.lineSize)
+	if finfo.Size() < offset {
+		_, e = reader.fRead.Seek(0, io.SeekStart)
+		if e != nil {
+			return nil, e
+		}
+
+		reader.followReset()
+
+		return nil, nil
+	}
+
+	pinfo, e := os.Stat(reader.Input)
+	if e != nil {
+		// Input file has been moved, but the new file has not
+		// been created yet.
+		if os.IsNotExist(e) {
+			return nil, nil
+		}
+		return nil, e
+	}
+
+	if os.SameFile(finfo, pinfo) {
+		return nil, nil
+	}
+
+	// Input file has been rotated, but the old file still has lines
+	// that has not been read.
+	if finfo.Size() > offset {
+		return nil, nil
+	}
+
+	fnew, e := os.OpenFile(reader.Input, os.O_RDONLY, 0600)
+	if e != nil {
+		return nil, e
+	}
+
+	e = reader.fRead.Close()
+	if e != nil {
+		_ = fnew.Close()
+		return nil, e
+	}
+
+	if reader.lineSize > 0 {
+		line = append([]byte{}, reader.partial...)
+		if reader.isTooLong {
+			e = ErrLineTooLong
+		}
+	}
+
+	reader.fRead = fnew
+	reader.followReset()
+
+	return line, e
+}
+
+//
+// followReset reset the read buffer and position after input file has been
+// truncated or rotated, and skip the first Skip lines in input file again.
+//
+func (reader *Reader) followReset() {
+	reader.followSkip = reader.Skip
+	reader.bufRead = bufio.NewReader(reader.fRead)
+	reader.partial = nil
+	reader.lineSize = 0
+	reader.isTooLong = false
+	reader.checkpoint.Offset = 0
+	reader.checkpoint.Line = 0
+}
@@ -0,0 +1,273 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/dsv"
+)
+
+func appendFile(t *testing.T, file, content string) {
+	f, e := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = f.WriteString(content)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = f.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReaderFollow(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.log")
+
+	appendFile(t, input, "a,1\nb,2\nc,")
+
+	reader := &dsv.Reader{
+		Input:          input,
+		Rejected:       filepath.Join(dir, "rejected.dat"),
+		Follow:         true,
+		FollowInterval: 10,
+		MaxRows:        -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "name",
+			Separator: ",",
+		}, {
+			Name: "value",
+			Type: "integer",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// The incomplete line "c," should not be parsed.
+	n, e := dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, 2, n, true)
+	checkDataset(t, reader, "&[a 1]&[b 2]")
+
+	// Complete the last line.
+	appendFile(t, input, "3\n")
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[c 3]")
+
+	// Rotate the input file.
+	e = os.Rename(input, input+".1")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	appendFile(t, input, "d,40\n")
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[d 40]")
+
+	// Truncate the input file.
+	e = ioutil.WriteFile(input, []byte("e,5\n"), 0600)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[e 5]")
+
+	reader.StopFollow()
+
+	n, e = dsv.Read(reader)
+
+	assert(t, 0, n, true)
+	assert(t, io.EOF, e, true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+//
+// TestReaderFollowRotate test reading the rest of rotated input file, and
+// skipping the header line in the new input file.
+//
+func TestReaderFollowRotate(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "name,value\na,1\n")
+
+	reader := &dsv.Reader{
+		Input:          input,
+		Rejected:       filepath.Join(dir, "rejected.dat"),
+		Skip:           1,
+		Follow:         true,
+		FollowInterval: 10,
+		MaxRows:        -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "name",
+			Separator: ",",
+		}, {
+			Name: "value",
+			Type: "integer",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[a 1]")
+
+	// Lines written before the input file is rotated, where the last
+	// line does not end with new line.
+	appendFile(t, input, "b,2\nc,3")
+
+	e = os.Rename(input, input+".1")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	appendFile(t, input, "name,value\nd,4\n")
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[b 2]")
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[c 3]&[d 4]")
+
+	// Truncate the input file, with content shorter than before.
+	e = ioutil.WriteFile(input, []byte("h\ne,5\n"), 0600)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[e 5]")
+
+	reader.StopFollow()
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReadWriterRunFollow(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "a,1\nb,2\n")
+	output := filepath.Join(dir, "output.dat")
+
+	rw := &dsv.ReadWriter{}
+
+	rw.Reader.Input = input
+	rw.Reader.Rejected = filepath.Join(dir, "rejected.dat")
+	rw.Reader.Follow = true
+	rw.Reader.FollowInterval = 10
+	rw.Reader.InputMetadata = []dsv.Metadata{{
+		Name:      "name",
+		Separator: ",",
+	}, {
+		Name: "value",
+		Type: "integer",
+	}}
+	rw.Writer.OutputMetadata = rw.Reader.InputMetadata
+
+	e := rw.Reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = rw.Writer.OpenOutput(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	type result struct {
+		stats *dsv.Stats
+		e     error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		stats, e := rw.Run(ctx)
+		done <- result{stats, e}
+	}()
+
+	// Let the reader wait for new line, and then cancel it.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	var got result
+
+	select {
+	case got = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run does not return after context is canceled")
+	}
+
+	assert(t, context.Canceled, got.e, true)
+	assert(t, 2, got.stats.RowsRead, true)
+	assert(t, 2, got.stats.RowsWritten, true)
+
+	e = rw.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := ioutil.ReadFile(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "a,1\nb,2\n", string(out), true)
+}
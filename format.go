@@ -0,0 +1,131 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"fmt"
+	"github.com/shuLhan/tabula"
+	"strings"
+	"unicode/utf8"
+)
+
+//
+// formatRecord convert the record value to string using the output options in
+// metadata.
+//
+// (1) String value is converted to letter case, truncated to maximum length,
+//     and formatted using Format, in that order.
+// (2) Number value is formatted using Format, or using the locale and
+//     decimals in metadata.
+// (3) Number value is padded with zeros up to ZeroPad characters.
+//
+func formatRecord(rec *tabula.Record, md *Metadata) (v []byte) {
+	// (1)
+	if rec.Type() == tabula.TString {
+		v = rec.Bytes()
+
+		if md.GetCase() != "" {
+			v = transformValue(&fieldPlan{caseMode: md.GetCase()}, v)
+		}
+
+		if max := md.GetMaxLength(); max > 0 &&
+			utf8.RuneCount(v) > max {
+			v = v[:charIndex(v, 0, max)]
+		}
+
+		if format := md.GetFormat(); format != "" {
+			v = []byte(fmt.Sprintf(format, string(v)))
+		}
+
+		return v
+	}
+
+	// (2)
+	switch {
+	case md.GetFormat() != "":
+		v = []byte(formatPrintf(md.GetFormat(), rec))
+	case md.GetDecimals() > 0 || hasLocale(md):
+		v = formatNumber(rec, md)
+	default:
+		v = rec.Bytes()
+	}
+
+	// (3)
+	if width := md.GetZeroPad(); width > 0 {
+		v = zeroPad(v, width)
+	}
+
+	return v
+}
+
+//
+// formatVerb return the verb of the first value in printf format, or 0 if
+// format does not have verb.
+//
+func formatVerb(format string) byte {
+	for x := 0; x < len(format); x++ {
+		if format[x] != '%' {
+			continue
+		}
+		x++
+		for x < len(format) &&
+			strings.IndexByte("+-# 0123456789.", format[x]) >= 0 {
+			x++
+		}
+		if x < len(format) && format[x] != '%' {
+			return format[x]
+		}
+	}
+	return 0
+}
+
+//
+// formatPrintf format the number record using printf format.
+// The record value is converted to integer or real based on the verb in
+// format, so "%d" can be used on real column and "%.2f" on integer column.
+//
+func formatPrintf(format string, rec *tabula.Record) string {
+	var arg interface{}
+
+	switch formatVerb(format) {
+	case 'd', 'b', 'o', 'x', 'X', 'c':
+		arg = rec.Integer()
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		arg = rec.Float()
+	case 's', 'q':
+		arg = rec.String()
+	default:
+		if rec.Type() == tabula.TInteger {
+			arg = rec.Integer()
+		} else {
+			arg = rec.Float()
+		}
+	}
+
+	return fmt.Sprintf(format, arg)
+}
+
+//
+// zeroPad insert zeros after the sign of number `v` until its length is equal
+// with `width` characters.
+//
+func zeroPad(v []byte, width int) []byte {
+	n := width - utf8.RuneCount(v)
+	if n <= 0 {
+		return v
+	}
+
+	p := 0
+	if len(v) > 0 && (v[0] == '-' || v[0] == '+') {
+		p = 1
+	}
+
+	out := make([]byte, 0, len(v)+n)
+	out = append(out, v[:p]...)
+	out = append(out, strings.Repeat("0", n)...)
+	out = append(out, v[p:]...)
+
+	return out
+}
@@ -0,0 +1,85 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// hllPrecision is the number of bits in hash that is used as index
+	// of register. The standard error of estimation is about
+	// 1.04/sqrt(2^hllPrecision), or 0.8%.
+	hllPrecision = 14
+	// hllRegisters is the number of registers.
+	hllRegisters = 1 << hllPrecision
+)
+
+//
+// hyperLogLog estimate the number of distinct values using constant memory.
+//
+type hyperLogLog struct {
+	registers [hllRegisters]uint8
+}
+
+//
+// hllHash return the 64 bit hash of value. The FNV-1a hash is mixed using
+// the finalizer of SplitMix64, so the bits are distributed evenly.
+//
+func hllHash(v string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(v))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+//
+// add the value to the estimation.
+//
+func (hll *hyperLogLog) add(v string) {
+	h := hllHash(v)
+	idx := h >> (64 - hllPrecision)
+	w := h<<hllPrecision | 1<<(hllPrecision-1)
+	rank := uint8(bits.LeadingZeros64(w) + 1)
+
+	if rank > hll.registers[idx] {
+		hll.registers[idx] = rank
+	}
+}
+
+//
+// count return the estimated number of distinct values.
+// Small number of distinct values is estimated using linear counting.
+//
+func (hll *hyperLogLog) count() int {
+	m := float64(hllRegisters)
+	sum := 0.0
+	zeros := 0
+
+	for _, r := range hll.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+
+	return int(est + 0.5)
+}
@@ -0,0 +1,120 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// DefIndexExt define the extension that will be appended to input
+	// file name, to create the index file.
+	DefIndexExt = ".index"
+	// DefIndexStep define the default number of records between two
+	// entries in index.
+	DefIndexStep = 1024
+)
+
+//
+// IndexEntry contain the position of record in input file.
+//
+type IndexEntry struct {
+	// Offset is the byte offset where the record start.
+	Offset int64 `json:"Offset"`
+	// Line is the number of physical line before the record.
+	Line int `json:"Line"`
+}
+
+//
+// Index contain the position of every n-th record in input file, where n is
+// defined by Step.
+//
+// Record is counted after the skipped lines, including the record that is
+// rejected, and a record with multiple lines (for example, the value in
+// quote contain new line) is counted as one.
+//
+type Index struct {
+	// Size is the size of input file when the index is build.
+	Size int64 `json:"Size"`
+	// ModTime is the modification time of input file, in Unix
+	// nanoseconds, when the index is build.
+	ModTime int64 `json:"ModTime"`
+	// Step is the number of records between two entries.
+	Step int `json:"Step"`
+	// Entries contain the position of record 0, Step, 2*Step, and so on.
+	Entries []IndexEntry `json:"Entries"`
+}
+
+//
+// LoadIndex read index from file.
+// If file is not exist, it will return nil index without error.
+//
+func LoadIndex(file string) (idx *Index, e error) {
+	b, e := ioutil.ReadFile(file)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return nil, nil
+		}
+		return nil, e
+	}
+
+	idx = &Index{}
+
+	e = json.Unmarshal(b, idx)
+	if e != nil {
+		return nil, e
+	}
+
+	return idx, nil
+}
+
+//
+// Save index to file.
+//
+func (idx *Index) Save(file string) (e error) {
+	b, e := json.Marshal(idx)
+	if e != nil {
+		return e
+	}
+
+	return ioutil.WriteFile(file, b, 0600)
+}
+
+//
+// IsValid return true if the input file `file` has not been changed since
+// the index is build, by comparing their size and modification time.
+//
+func (idx *Index) IsValid(file string) bool {
+	fi, e := os.Stat(file)
+	if e != nil {
+		return false
+	}
+
+	return fi.Size() == idx.Size && fi.ModTime().UnixNano() == idx.ModTime
+}
+
+//
+// Find return the entry before or at record `n` and the number of records
+// between the entry and `n`.
+// If `n` is negative, it will return ErrInvalidOffset.
+// If index is empty, it will return nil entry.
+//
+func (idx *Index) Find(n int) (entry *IndexEntry, rest int, e error) {
+	if n < 0 {
+		return nil, 0, ErrInvalidOffset
+	}
+	if len(idx.Entries) == 0 || idx.Step <= 0 {
+		return nil, 0, nil
+	}
+
+	x := n / idx.Step
+	if x >= len(idx.Entries) {
+		x = len(idx.Entries) - 1
+	}
+
+	return &idx.Entries[x], n - (x * idx.Step), nil
+}
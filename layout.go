@@ -0,0 +1,147 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"bytes"
+	"github.com/shuLhan/tabula"
+)
+
+//
+// Layout define the format of one record type in input file that contain
+// multiple record types, for example header, detail, and summary lines.
+//
+// Layout is selected by matching the line with Prefix, or by matching the
+// value of the first field with Value. Each layout has their own metadata and
+// dataset.
+//
+type Layout struct {
+	// Name of layout.
+	Name string `json:"Name"`
+	// Prefix is the string at the beginning of line that select this
+	// layout, for example "H" or "D".
+	Prefix string `json:"Prefix"`
+	// Value is the value of the first field that select this layout.
+	// The first field is cut using the separator of the first metadata,
+	// or the whole line if the first metadata does not have separator.
+	Value string `json:"Value"`
+	// InputMetadata define format for each column in this layout.
+	InputMetadata []Metadata `json:"InputMetadata"`
+
+	// dataset contain the rows that has been read using this layout.
+	dataset interface{}
+	// plan is the compiled InputMetadata.
+	plan *parsePlan
+}
+
+//
+// init initialize the layout metadata and create the dataset with `mode`.
+//
+func (layout *Layout) init(mode int, maxFieldBytes int) {
+	ds := &tabula.Dataset{}
+	ds.SetMode(mode)
+
+	mds := layout.GetInputMetadata()
+	for x, md := range mds {
+		layout.InputMetadata[x].Init()
+
+		if !md.GetSkip() {
+			ds.PushColumn(tabula.Column{
+				Type:       md.GetType(),
+				Name:       md.GetName(),
+				ValueSpace: md.GetValueSpace(),
+			})
+		}
+	}
+
+	layout.dataset = ds
+	layout.plan = newParsePlan(mds)
+	layout.plan.maxFieldBytes = maxFieldBytes
+}
+
+//
+// GetName return the name of layout.
+//
+func (layout *Layout) GetName() string {
+	return layout.Name
+}
+
+//
+// GetInputMetadata return pointer to slice of layout metadata.
+//
+func (layout *Layout) GetInputMetadata() []MetadataInterface {
+	md := make([]MetadataInterface, len(layout.InputMetadata))
+	for i := range layout.InputMetadata {
+		md[i] = &layout.InputMetadata[i]
+	}
+
+	return md
+}
+
+//
+// GetDataset return the dataset of layout.
+//
+func (layout *Layout) GetDataset() interface{} {
+	return layout.dataset
+}
+
+//
+// isMatch return true if line match with layout Prefix or Value.
+//
+func (layout *Layout) isMatch(line []byte) bool {
+	if len(layout.Prefix) > 0 {
+		return bytes.HasPrefix(line, []byte(layout.Prefix))
+	}
+	if len(layout.Value) == 0 {
+		return false
+	}
+
+	v := line
+	if layout.plan != nil && len(layout.plan.fields) > 0 {
+		f := &layout.plan.fields[0]
+		if f.sepIsWhitespace {
+			v, _ = parsingWhitespace(line, 0)
+		} else if len(f.sep) > 0 {
+			x := bytes.Index(line, f.sep)
+			if x >= 0 {
+				v = line[:x]
+			}
+		}
+	}
+
+	return string(v) == layout.Value
+}
+
+//
+// GetLayout return the layout with `name`, or nil if not found.
+//
+func (reader *Reader) GetLayout(name string) *Layout {
+	for x := range reader.Layouts {
+		if reader.Layouts[x].Name == name {
+			return &reader.Layouts[x]
+		}
+	}
+	return nil
+}
+
+//
+// SelectLayout return the first layout that match with `line`.
+// If no layout is match, it will return nil, so the line will be parsed using
+// reader InputMetadata, or ErrNoLayout if reader does not have InputMetadata.
+//
+func (reader *Reader) SelectLayout(line []byte) (*Layout, error) {
+	if len(reader.Layouts) == 0 {
+		return nil, nil
+	}
+	for x := range reader.Layouts {
+		if reader.Layouts[x].isMatch(line) {
+			return &reader.Layouts[x], nil
+		}
+	}
+	if len(reader.InputMetadata) == 0 {
+		return nil, ErrNoLayout
+	}
+	return nil, nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"github.com/shuLhan/tabula"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefDecimalSeparator define the default decimal separator in real
+	// number.
+	DefDecimalSeparator = "."
+)
+
+//
+// hasLocale return true if metadata has one of the locale options for
+// number.
+//
+func hasLocale(md LocaleMetadataInterface) bool {
+	return md.GetDecimalSeparator() != DefDecimalSeparator ||
+		md.GetGroupingSeparator() != "" ||
+		md.GetCurrency() != "" ||
+		md.IsPercent()
+}
+
+//
+// initLocale set the locale options for number in field plan from metadata.
+// The locale options is used only if column type is integer or real.
+//
+func (f *fieldPlan) initLocale(md MetadataInterface) {
+	lmd := getLocale(md)
+
+	f.isLocale = f.t != tabula.TString && hasLocale(lmd)
+	f.decimalSep = lmd.GetDecimalSeparator()
+	f.groupingSep = lmd.GetGroupingSeparator()
+	f.currency = lmd.GetCurrency()
+	f.percent = lmd.IsPercent()
+}
+
+//
+// parsingNumber convert the number in value from metadata locale to the
+// format that can be parsed by record.
+//
+// (1) Remove the currency symbol, if column has currency.
+// (2) Remove the grouping separators.
+// (3) Remove the percent sign, if column is percent.
+// (4) Replace the decimal separator with ".".
+// (5) Divide the value by 100, if column is percent and value has percent
+//     sign.
+//
+func parsingNumber(f *fieldPlan, v []byte) []byte {
+	s := string(v)
+
+	// (1)
+	if f.currency != "" {
+		s = strings.Replace(s, f.currency, "", -1)
+	}
+
+	s = strings.Map(func(r rune) rune {
+		if f.currency != "" && unicode.Is(unicode.Sc, r) {
+			return -1
+		}
+		// (2)
+		if f.groupingSep != "" && strings.ContainsRune(f.groupingSep, r) {
+			return -1
+		}
+		return r
+	}, s)
+
+	s = strings.TrimSpace(s)
+
+	// (3)
+	isPercent := false
+	if f.percent && strings.HasSuffix(s, "%") {
+		s = strings.TrimSpace(s[:len(s)-1])
+		isPercent = true
+	}
+
+	// (4)
+	if f.decimalSep != DefDecimalSeparator {
+		s = strings.Replace(s, f.decimalSep, DefDecimalSeparator, 1)
+	}
+
+	// (5)
+	if isPercent {
+		f64, e := strconv.ParseFloat(s, 64)
+		if e == nil {
+			s = strconv.FormatFloat(f64/100, 'f', -1, 64)
+		}
+	}
+
+	return []byte(s)
+}
+
+//
+// formatNumber convert the integer or real record to string using the locale
+// options and decimals in metadata.
+//
+// (1) If metadata is percent, multiply the value by 100. If metadata has
+//     decimals, the value is written with fixed number of digits after the
+//     decimal separator.
+// (2) Group the integer part of value using the first grouping separator.
+// (3) Replace the decimal point with the decimal separator.
+// (4) Append the percent sign, if metadata is percent.
+// (5) Add the currency, as prefix or suffix.
+//
+func formatNumber(rec *tabula.Record, md *Metadata) []byte {
+	var s string
+
+	prec := -1
+	if decimals := md.GetDecimals(); decimals > 0 {
+		prec = decimals
+	}
+
+	// (1)
+	switch {
+	case rec.Type() == tabula.TInteger && prec < 0:
+		i64 := rec.Integer()
+		if md.IsPercent() {
+			i64 *= 100
+		}
+		s = strconv.FormatInt(i64, 10)
+	default:
+		f64 := rec.Float()
+		if md.IsPercent() {
+			f64 *= 100
+		}
+		s = strconv.FormatFloat(f64, 'f', prec, 64)
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if x := strings.IndexByte(s, '.'); x >= 0 {
+		intPart, fracPart = s[:x], s[x+1:]
+	}
+
+	// (2)
+	if grouping := md.GetGroupingSeparator(); grouping != "" {
+		r, _ := utf8.DecodeRuneInString(grouping)
+		intPart = groupDigits(intPart, string(r))
+	}
+
+	s = sign + intPart
+
+	// (3)
+	if fracPart != "" {
+		s += md.GetDecimalSeparator() + fracPart
+	}
+
+	// (4)
+	if md.IsPercent() {
+		s += "%"
+	}
+
+	// (5)
+	if currency := md.GetCurrency(); currency != "" {
+		if md.IsCurrencySuffix() {
+			s += currency
+		} else {
+			s = currency + s
+		}
+	}
+
+	return []byte(s)
+}
+
+//
+// groupDigits insert separator `sep` between each three digits, from the
+// right.
+//
+func groupDigits(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var b strings.Builder
+
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+
+	for x := head; x < len(digits); x += 3 {
+		if x > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[x : x+3])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,179 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+//
+// Logger is the interface for leveled and structured logging of reader and
+// writer events.
+// The `args` is list of alternating key and value, for example
+// `"path", "input.dat", "line", 10`.
+//
+// Logger is compatible with *slog.Logger from package "log/slog".
+//
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+//
+// LoggerInterface is the optional interface for reader and writer that log
+// their events using Logger.
+//
+type LoggerInterface interface {
+	GetLogger() Logger
+}
+
+//
+// NopLogger is a logger that discard all events.
+//
+type NopLogger struct{}
+
+//
+// Debug discard the event.
+//
+func (NopLogger) Debug(msg string, args ...interface{}) {}
+
+//
+// Info discard the event.
+//
+func (NopLogger) Info(msg string, args ...interface{}) {}
+
+//
+// Warn discard the event.
+//
+func (NopLogger) Warn(msg string, args ...interface{}) {}
+
+//
+// Error discard the event.
+//
+func (NopLogger) Error(msg string, args ...interface{}) {}
+
+//
+// stdLogger is the default logger, which print the warning events, like
+// rejected line, to standard error and the error events using standard
+// logger, and discard the debug and info events.
+//
+type stdLogger struct{}
+
+var defaultLogger Logger = stdLogger{}
+
+func (stdLogger) Debug(msg string, args ...interface{}) {}
+
+func (stdLogger) Info(msg string, args ...interface{}) {}
+
+func (stdLogger) Warn(msg string, args ...interface{}) {
+	fmt.Fprintln(os.Stderr, formatEvent("WARN", msg, args))
+}
+
+func (stdLogger) Error(msg string, args ...interface{}) {
+	log.Print(formatEvent("ERROR", msg, args))
+}
+
+//
+// formatEvent format the event into single line,
+//
+//	dsv: LEVEL msg key=value ...
+//
+// Value that contain space, quote, or equal sign is quoted.
+//
+func formatEvent(level, msg string, args []interface{}) string {
+	var buf bytes.Buffer
+
+	buf.WriteString("dsv: ")
+	buf.WriteString(level)
+	buf.WriteByte(' ')
+	buf.WriteString(msg)
+
+	for x := 0; x < len(args); x += 2 {
+		key := "!BADKEY"
+		if x+1 < len(args) {
+			key = fmt.Sprint(args[x])
+		} else {
+			x--
+		}
+
+		v := fmt.Sprint(args[x+1])
+		if v == "" || strings.ContainsAny(v, " \t\r\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(v)
+	}
+
+	return buf.String()
+}
+
+//
+// getLogger return the logger of reader or writer `v`, or the default logger
+// if `v` does not implement LoggerInterface.
+//
+func getLogger(v interface{}) Logger {
+	if l, ok := v.(LoggerInterface); ok {
+		return l.GetLogger()
+	}
+	return defaultLogger
+}
+
+//
+// GetLogger return the reader Logger, or the default logger if its nil.
+//
+func (reader *Reader) GetLogger() Logger {
+	if reader.Logger == nil {
+		return defaultLogger
+	}
+	return reader.Logger
+}
+
+//
+// SetLogger set the reader Logger.
+//
+func (reader *Reader) SetLogger(logger Logger) {
+	reader.Logger = logger
+}
+
+//
+// GetLogger return the writer Logger, or the default logger if its nil.
+//
+func (writer *Writer) GetLogger() Logger {
+	if writer.Logger == nil {
+		return defaultLogger
+	}
+	return writer.Logger
+}
+
+//
+// SetLogger set the writer Logger.
+//
+func (writer *Writer) SetLogger(logger Logger) {
+	writer.Logger = logger
+}
+
+//
+// GetLogger return the reader logger.
+//
+func (dsv *ReadWriter) GetLogger() Logger {
+	return dsv.Reader.GetLogger()
+}
+
+//
+// SetLogger set the logger of reader and writer.
+//
+func (dsv *ReadWriter) SetLogger(logger Logger) {
+	dsv.Reader.SetLogger(logger)
+	dsv.Writer.SetLogger(logger)
+}
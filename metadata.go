@@ -5,10 +5,29 @@
 package dsv
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/shuLhan/tabula"
-	"log"
 	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// AlignLeft define the value of column is written at the left and
+	// padded at the right side.
+	AlignLeft = "left"
+	// AlignRight define the value of column is written at the right and
+	// padded at the left side.
+	AlignRight = "right"
+	// DefPad define the default pad character for fixed width column.
+	DefPad = " "
+	// SeparatorModeWhitespace define the separator mode where any run of
+	// white spaces is treated as one separator.
+	SeparatorModeWhitespace = "whitespace"
+	// SeparatorWhitespace define the separator value that is equal to
+	// SeparatorModeWhitespace.
+	SeparatorWhitespace = `\s+`
 )
 
 //
@@ -23,7 +42,24 @@ type Metadata struct {
 	// T type of column in integer.
 	T int
 	// Separator for column in record.
+	// In JSON, separator can be a list of alternative separators, where
+	// the first one is used when writing the column.
 	Separator string `json:"Separator"`
+	// Separators define the list of alternative separators, for input
+	// that use different separators depending on how its created.
+	Separators []string `json:"Separators"`
+	// SeparatorMatch define which alternative separator is used when
+	// more than one separators match at the same position, either
+	// "first" (the first in list) or "longest". Default is "first".
+	SeparatorMatch string `json:"SeparatorMatch"`
+	// SeparatorMode define the class of separator. If its "whitespace",
+	// any run of white spaces, including tab and Unicode spaces, is
+	// treated as one separator, and Separator is ignored.
+	// Separator with value "\\s+" is equal to this mode.
+	SeparatorMode string `json:"SeparatorMode"`
+	// Remainder if its true, the column will take the rest of line,
+	// including the separators in it.
+	Remainder bool `json:"Remainder"`
 	// LeftQuote define the characters that enclosed the column in the left
 	// side.
 	LeftQuote string `json:"LeftQuote"`
@@ -35,6 +71,85 @@ type Metadata struct {
 	Skip bool `json:"Skip"`
 	// ValueSpace contain the possible value in records
 	ValueSpace []string `json:"ValueSpace"`
+	// Width define the number of characters in fixed width column.
+	// Fixed width column is cut by character position, ignoring the
+	// separator and quotes.
+	Width int `json:"Width"`
+	// Start define the position of the first character of fixed width
+	// column in line, start from 1.
+	Start int `json:"Start"`
+	// End define the position of the last character of fixed width
+	// column in line, inclusive. If Width is zero, the width is computed
+	// from Start and End.
+	End int `json:"End"`
+	// Pad define the single character that is used to fill the fixed
+	// width column. When reading, the pad is removed from value. Default
+	// is space.
+	Pad string `json:"Pad"`
+	// Align define the position of value in fixed width column, either
+	// "left" or "right". Default is "left".
+	Align string `json:"Align"`
+	// Trim define which white spaces in value will be removed: "left",
+	// "right", "both", or "none". Default is "none".
+	Trim string `json:"Trim"`
+	// Case define the conversion of letter case in value: "upper",
+	// "lower", or "title". Default is empty, no conversion.
+	Case string `json:"Case"`
+	// Normalize define the Unicode normalization form of value: "NFC",
+	// "NFD", "NFKC", or "NFKD". Default is empty, no normalization.
+	Normalize string `json:"Normalize"`
+	// CollapseSpace if its true, each run of white spaces in value will
+	// be replaced with single space.
+	CollapseSpace bool `json:"CollapseSpace"`
+	// DecimalSeparator define the character that separate the integer and
+	// fraction part in number, for example "," in "1.234,5".
+	// Default is ".".
+	DecimalSeparator string `json:"DecimalSeparator"`
+	// GroupingSeparator define the characters that separate each group
+	// of thousand in number, for example "." in "1.234,5". Each
+	// character in it is removed when reading, for example "'\u2009"
+	// will remove apostrophe and thin space. When writing, only the
+	// first character is used. Default is empty, no grouping.
+	GroupingSeparator string `json:"GroupingSeparator"`
+	// Currency define the currency symbol in number. When reading, the
+	// currency and all Unicode currency symbols are removed from value.
+	// When writing, the currency is added before the number, or after it
+	// if CurrencySuffix is true. Default is empty.
+	Currency string `json:"Currency"`
+	// CurrencySuffix if its true, the currency is written after the
+	// number.
+	CurrencySuffix bool `json:"CurrencySuffix"`
+	// Percent if its true, value with percent sign is divided by 100 when
+	// reading, and value is multiplied by 100 and written with percent
+	// sign when writing. Percent can not be used in integer input column.
+	// Default is false.
+	Percent bool `json:"Percent"`
+	// Format define the printf format that is used to write the value,
+	// for example "%.2f" or "%05d". The value is converted to integer or
+	// real based on the verb in format. Default is empty.
+	Format string `json:"Format"`
+	// Decimals define the number of digits after decimal separator when
+	// writing number. Default is 0, the number is written with the
+	// minimum digits needed.
+	Decimals int `json:"Decimals"`
+	// ZeroPad define the minimum number of characters of number when
+	// writing. Number that is shorter is padded with zeros after its
+	// sign. ZeroPad is independent of Width. Default is 0, no padding.
+	ZeroPad int `json:"ZeroPad"`
+	// MaxLength define the maximum number of characters of string value
+	// when writing. Longer value is truncated. Default is 0, no limit.
+	MaxLength int `json:"MaxLength"`
+	// From define the name of input column that is written to this output
+	// column, if its different with Name.
+	From string `json:"From"`
+	// Value define the constant string that is written to this output
+	// column, instead of the value from input column. The value is
+	// converted to the column Type before its formatted.
+	Value string `json:"Value"`
+	// Expr define the expression that compute the value of this output
+	// column from input columns, for example `first + " " + last`,
+	// `price * qty`, or `substr(date, 0, 4)`.
+	Expr string `json:"Expr"`
 }
 
 //
@@ -74,6 +189,34 @@ func (md *Metadata) Init() {
 	}
 }
 
+//
+// checkMetadata return an error if one of metadata has invalid option.
+//
+func checkMetadata(mds []MetadataInterface) error {
+	for _, md := range mds {
+		pad := getFixedWidth(md).GetPad()
+		if utf8.RuneCountInString(pad) != 1 {
+			return fmt.Errorf("dsv: md %s: Pad %q must be a single character",
+				md.GetName(), pad)
+		}
+	}
+	return nil
+}
+
+//
+// checkInputMetadata return an error if one of input metadata has option that
+// can not be used when reading. The metadata must be initialized.
+//
+func checkInputMetadata(mds []MetadataInterface) error {
+	for _, md := range mds {
+		if getLocale(md).IsPercent() && md.GetType() == tabula.TInteger {
+			return fmt.Errorf("dsv: md %s: Percent can not be used"+
+				" in integer column", md.GetName())
+		}
+	}
+	return nil
+}
+
 //
 // GetName return the name of metadata.
 //
@@ -96,12 +239,65 @@ func (md *Metadata) GetTypeName() string {
 }
 
 //
-// GetSeparator return the field separator.
+// GetSeparator return the field separator. If Separator is empty, it will
+// return the first alternative separator.
 //
 func (md *Metadata) GetSeparator() string {
+	if md.Separator == "" && len(md.Separators) > 0 {
+		return md.Separators[0]
+	}
 	return md.Separator
 }
 
+//
+// GetSeparators return list of alternative separators, including Separator,
+// or nil if metadata does not have alternative separators.
+//
+func (md *Metadata) GetSeparators() (seps []string) {
+	if len(md.Separators) == 0 {
+		return nil
+	}
+	if md.Separator != "" {
+		seps = append(seps, md.Separator)
+	}
+	for _, sep := range md.Separators {
+		if sep != "" && sep != md.Separator {
+			seps = append(seps, sep)
+		}
+	}
+	return seps
+}
+
+//
+// GetSeparatorMatch return "longest" if SeparatorMatch is longest, otherwise
+// it will return "first".
+//
+func (md *Metadata) GetSeparatorMatch() string {
+	if strings.ToLower(md.SeparatorMatch) == SeparatorMatchLongest {
+		return SeparatorMatchLongest
+	}
+	return SeparatorMatchFirst
+}
+
+//
+// GetSeparatorMode return "whitespace" if SeparatorMode is whitespace or
+// Separator is "\\s+", otherwise it will return empty string.
+//
+func (md *Metadata) GetSeparatorMode() string {
+	if strings.ToLower(md.SeparatorMode) == SeparatorModeWhitespace ||
+		md.Separator == SeparatorWhitespace {
+		return SeparatorModeWhitespace
+	}
+	return ""
+}
+
+//
+// IsRemainder return true if column will take the rest of line.
+//
+func (md *Metadata) IsRemainder() bool {
+	return md.Remainder
+}
+
 //
 // GetLeftQuote return the string used in the beginning of record value.
 //
@@ -130,6 +326,171 @@ func (md *Metadata) GetValueSpace() []string {
 	return md.ValueSpace
 }
 
+//
+// GetWidth return the number of characters in fixed width column, or 0 if
+// column is not fixed width.
+//
+func (md *Metadata) GetWidth() int {
+	if md.Width > 0 {
+		return md.Width
+	}
+	if md.Start > 0 && md.End >= md.Start {
+		return md.End - md.Start + 1
+	}
+	return 0
+}
+
+//
+// GetStart return the position of the first character of fixed width column,
+// or 0 if column start after the previous column.
+//
+func (md *Metadata) GetStart() int {
+	return md.Start
+}
+
+//
+// GetPad return the pad character of fixed width column.
+//
+func (md *Metadata) GetPad() string {
+	if md.Pad == "" {
+		return DefPad
+	}
+	return md.Pad
+}
+
+//
+// GetAlign return the alignment of value in fixed width column.
+//
+func (md *Metadata) GetAlign() string {
+	if strings.ToLower(md.Align) == AlignRight {
+		return AlignRight
+	}
+	return AlignLeft
+}
+
+//
+// GetTrim return which white spaces in value will be removed, in lower case.
+//
+func (md *Metadata) GetTrim() string {
+	return strings.ToLower(md.Trim)
+}
+
+//
+// GetCase return the conversion of letter case in value, in lower case.
+//
+func (md *Metadata) GetCase() string {
+	return strings.ToLower(md.Case)
+}
+
+//
+// GetNormalize return the Unicode normalization form, in upper case.
+//
+func (md *Metadata) GetNormalize() string {
+	return strings.ToUpper(md.Normalize)
+}
+
+//
+// IsCollapseSpace return true if run of white spaces in value will be
+// replaced with single space.
+//
+func (md *Metadata) IsCollapseSpace() bool {
+	return md.CollapseSpace
+}
+
+//
+// GetDecimalSeparator return the decimal separator in number.
+//
+func (md *Metadata) GetDecimalSeparator() string {
+	if md.DecimalSeparator == "" {
+		return DefDecimalSeparator
+	}
+	return md.DecimalSeparator
+}
+
+//
+// GetGroupingSeparator return the grouping separators in number.
+//
+func (md *Metadata) GetGroupingSeparator() string {
+	return md.GroupingSeparator
+}
+
+//
+// GetCurrency return the currency symbol in number.
+//
+func (md *Metadata) GetCurrency() string {
+	return md.Currency
+}
+
+//
+// IsCurrencySuffix return true if currency is written after the number.
+//
+func (md *Metadata) IsCurrencySuffix() bool {
+	return md.CurrencySuffix
+}
+
+//
+// IsPercent return true if number is written as percent.
+//
+func (md *Metadata) IsPercent() bool {
+	return md.Percent
+}
+
+//
+// GetFormat return the printf format of value when writing.
+//
+func (md *Metadata) GetFormat() string {
+	return md.Format
+}
+
+//
+// GetDecimals return the number of digits after decimal separator when
+// writing number.
+//
+func (md *Metadata) GetDecimals() int {
+	return md.Decimals
+}
+
+//
+// GetZeroPad return the minimum number of characters of number, that is
+// padded with zeros, when writing.
+//
+func (md *Metadata) GetZeroPad() int {
+	return md.ZeroPad
+}
+
+//
+// GetMaxLength return the maximum number of characters of string value when
+// writing.
+//
+func (md *Metadata) GetMaxLength() int {
+	return md.MaxLength
+}
+
+//
+// GetFrom return the name of input column that is written to this column.
+// If From is empty, it will return the metadata name.
+//
+func (md *Metadata) GetFrom() string {
+	if md.From == "" {
+		return md.Name
+	}
+	return md.From
+}
+
+//
+// GetValue return the constant value of column.
+//
+func (md *Metadata) GetValue() string {
+	return md.Value
+}
+
+//
+// GetExpr return the expression that compute the value of column.
+//
+func (md *Metadata) GetExpr() string {
+	return md.Expr
+}
+
 //
 // IsEqual return true if this metadata equal with other instance, return false
 // otherwise.
@@ -147,16 +508,90 @@ func (md *Metadata) IsEqual(o MetadataInterface) bool {
 	if md.RightQuote != o.GetRightQuote() {
 		return false
 	}
+	ofw := getFixedWidth(o)
+	if md.GetWidth() != ofw.GetWidth() {
+		return false
+	}
+	if md.Start != ofw.GetStart() {
+		return false
+	}
 	return true
 }
 
+//
+// UnmarshalJSON decode metadata from JSON, where the Separator can be a
+// string or a list of alternative separators.
+//
+func (md *Metadata) UnmarshalJSON(b []byte) (e error) {
+	type metadata Metadata
+
+	raw := struct {
+		*metadata
+		Separator json.RawMessage `json:"Separator"`
+	}{
+		metadata: (*metadata)(md),
+	}
+
+	e = json.Unmarshal(b, &raw)
+	if e != nil {
+		return e
+	}
+
+	sep := bytes.TrimSpace(raw.Separator)
+	if len(sep) == 0 || string(sep) == "null" {
+		return nil
+	}
+	if sep[0] != '[' {
+		return json.Unmarshal(sep, &md.Separator)
+	}
+
+	var seps []string
+
+	e = json.Unmarshal(sep, &seps)
+	if e != nil {
+		return e
+	}
+	if len(seps) > 0 {
+		md.Separator = seps[0]
+		md.Separators = seps[1:]
+	}
+
+	return nil
+}
+
 //
 // String yes, it will print it JSON like format.
 //
 func (md *Metadata) String() string {
 	r, e := json.MarshalIndent(md, "", "\t")
 	if nil != e {
-		log.Print(e)
+		return "dsv: " + e.Error()
 	}
 	return string(r)
 }
+
+//
+// copyMetadata return the copy of list of metadata, including their list of
+// separators and value space.
+//
+func copyMetadata(mds []Metadata) (out []Metadata) {
+	if mds == nil {
+		return nil
+	}
+
+	out = make([]Metadata, len(mds))
+	copy(out, mds)
+
+	for x := range out {
+		if out[x].Separators != nil {
+			out[x].Separators = append(make([]string, 0,
+				len(out[x].Separators)), out[x].Separators...)
+		}
+		if out[x].ValueSpace != nil {
+			out[x].ValueSpace = append(make([]string, 0,
+				len(out[x].ValueSpace)), out[x].ValueSpace...)
+		}
+	}
+
+	return out
+}
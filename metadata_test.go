@@ -5,6 +5,7 @@
 package dsv_test
 
 import (
+	"encoding/json"
 	"github.com/shuLhan/dsv"
 	"testing"
 )
@@ -47,3 +48,71 @@ func TestMetadataIsEqual(t *testing.T) {
 		}
 	}
 }
+
+func TestMetadataUnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		in      string
+		expSep  string
+		expSeps []string
+	}{{
+		in:     `{"Name":"A","Separator":";"}`,
+		expSep: ";",
+	}, {
+		in:      `{"Name":"A","Separator":[";",","]}`,
+		expSep:  ";",
+		expSeps: []string{";", ","},
+	}, {
+		in:      `{"Name":"A","Separators":[";",","]}`,
+		expSep:  ";",
+		expSeps: []string{";", ","},
+	}}
+
+	for _, c := range cases {
+		md := dsv.Metadata{}
+
+		e := json.Unmarshal([]byte(c.in), &md)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, "A", md.GetName(), true)
+		assert(t, c.expSep, md.GetSeparator(), true)
+		assert(t, c.expSeps, md.GetSeparators(), true)
+	}
+}
+
+//
+// baseMetadata implement only the methods in MetadataInterface, without the
+// optional metadata interfaces.
+//
+type baseMetadata struct {
+	md dsv.Metadata
+}
+
+func (b *baseMetadata) Init()                   { b.md.Init() }
+func (b *baseMetadata) GetName() string         { return b.md.GetName() }
+func (b *baseMetadata) GetType() int            { return b.md.GetType() }
+func (b *baseMetadata) GetTypeName() string     { return b.md.GetTypeName() }
+func (b *baseMetadata) GetLeftQuote() string    { return b.md.GetLeftQuote() }
+func (b *baseMetadata) GetRightQuote() string   { return b.md.GetRightQuote() }
+func (b *baseMetadata) GetSeparator() string    { return b.md.GetSeparator() }
+func (b *baseMetadata) GetSkip() bool           { return b.md.GetSkip() }
+func (b *baseMetadata) GetValueSpace() []string { return b.md.GetValueSpace() }
+
+func (b *baseMetadata) IsEqual(o dsv.MetadataInterface) bool {
+	return b.md.IsEqual(o)
+}
+
+func TestMetadataIsEqualBase(t *testing.T) {
+	in := dsv.Metadata{
+		Name:      "A",
+		Separator: ",",
+	}
+	base := &baseMetadata{md: in}
+
+	assert(t, true, in.IsEqual(base), true)
+
+	in.Width = 3
+
+	assert(t, false, in.IsEqual(base), true)
+}
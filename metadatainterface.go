@@ -22,6 +22,99 @@ type MetadataInterface interface {
 	IsEqual(MetadataInterface) bool
 }
 
+//
+// SeparatorsMetadataInterface is the optional interface for input metadata
+// that has alternative separators, whitespace separator mode, or take the
+// rest of line.
+//
+type SeparatorsMetadataInterface interface {
+	GetSeparators() []string
+	GetSeparatorMatch() string
+	GetSeparatorMode() string
+	IsRemainder() bool
+}
+
+//
+// FixedWidthMetadataInterface is the optional interface for input metadata
+// of fixed width column.
+//
+type FixedWidthMetadataInterface interface {
+	GetWidth() int
+	GetStart() int
+	GetPad() string
+	GetAlign() string
+}
+
+//
+// TransformMetadataInterface is the optional interface for input metadata
+// that transform the value before converted to record.
+//
+type TransformMetadataInterface interface {
+	GetTrim() string
+	GetCase() string
+	GetNormalize() string
+	IsCollapseSpace() bool
+}
+
+//
+// LocaleMetadataInterface is the optional interface for input metadata that
+// read number in specific locale.
+//
+type LocaleMetadataInterface interface {
+	GetDecimalSeparator() string
+	GetGroupingSeparator() string
+	GetCurrency() string
+	IsCurrencySuffix() bool
+	IsPercent() bool
+}
+
+//
+// defMetadata contain the default value of all optional metadata options.
+// It is used in place of metadata that does not implement one of optional
+// metadata interfaces.
+//
+var defMetadata = &Metadata{}
+
+//
+// getSeparators return the alternative separators options of metadata.
+//
+func getSeparators(md MetadataInterface) SeparatorsMetadataInterface {
+	if v, ok := md.(SeparatorsMetadataInterface); ok {
+		return v
+	}
+	return defMetadata
+}
+
+//
+// getFixedWidth return the fixed width options of metadata.
+//
+func getFixedWidth(md MetadataInterface) FixedWidthMetadataInterface {
+	if v, ok := md.(FixedWidthMetadataInterface); ok {
+		return v
+	}
+	return defMetadata
+}
+
+//
+// getTransform return the value transformation options of metadata.
+//
+func getTransform(md MetadataInterface) TransformMetadataInterface {
+	if v, ok := md.(TransformMetadataInterface); ok {
+		return v
+	}
+	return defMetadata
+}
+
+//
+// getLocale return the number locale options of metadata.
+//
+func getLocale(md MetadataInterface) LocaleMetadataInterface {
+	if v, ok := md.(LocaleMetadataInterface); ok {
+		return v
+	}
+	return defMetadata
+}
+
 //
 // FindMetadata Given a slice of metadata, find `mdin` in the slice which has the
 // same name, ignoring metadata where Skip value is true.
@@ -43,3 +136,18 @@ func FindMetadata(mdin MetadataInterface, mds []MetadataInterface) (
 	}
 	return idx, mdout
 }
+
+//
+// rowMetadata return the metadata of columns that is saved in row, or
+// metadata where Skip value is false, so the metadata at index i describe the
+// record at index i in row.
+//
+func rowMetadata(mds []MetadataInterface) (rowMds []MetadataInterface) {
+	rowMds = make([]MetadataInterface, 0, len(mds))
+	for _, md := range mds {
+		if !md.GetSkip() {
+			rowMds = append(rowMds, md)
+		}
+	}
+	return rowMds
+}
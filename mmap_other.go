@@ -0,0 +1,27 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package dsv
+
+import (
+	"os"
+)
+
+//
+// mmapFile always return nil on system without mmap support, so the reader
+// will fallback to buffered reading.
+//
+func mmapFile(f *os.File) ([]byte, error) {
+	return nil, nil
+}
+
+//
+// munmapFile do nothing on system without mmap support.
+//
+func munmapFile(b []byte) error {
+	return nil
+}
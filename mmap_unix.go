@@ -0,0 +1,44 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package dsv
+
+import (
+	"os"
+	"syscall"
+)
+
+//
+// mmapFile map the content of file `f` into memory as read only.
+// It will return nil without error if the file is not a regular file (for
+// example a pipe or a device) or if the file is empty, so the caller can
+// fallback to buffered reading.
+//
+func mmapFile(f *os.File) (b []byte, e error) {
+	finfo, e := f.Stat()
+	if e != nil {
+		return nil, e
+	}
+	if !finfo.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	size := finfo.Size()
+	if size <= 0 || int64(int(size)) != size {
+		return nil, nil
+	}
+
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ,
+		syscall.MAP_SHARED)
+}
+
+//
+// munmapFile release the memory mapped by mmapFile.
+//
+func munmapFile(b []byte) error {
+	return syscall.Munmap(b)
+}
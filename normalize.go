@@ -0,0 +1,168 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"bytes"
+	"golang.org/x/text/unicode/norm"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// TrimNone define that white spaces in value are not removed.
+	TrimNone = "none"
+	// TrimLeft define that white spaces at the beginning of value are
+	// removed.
+	TrimLeft = "left"
+	// TrimRight define that white spaces at the end of value are removed.
+	TrimRight = "right"
+	// TrimBoth define that white spaces at the beginning and end of value
+	// are removed.
+	TrimBoth = "both"
+
+	// CaseUpper define that value is converted to upper case.
+	CaseUpper = "upper"
+	// CaseLower define that value is converted to lower case.
+	CaseLower = "lower"
+	// CaseTitle define that the first letter of each word in value is
+	// converted to upper case, and the rest to lower case.
+	CaseTitle = "title"
+
+	// NormNFC define the Unicode canonical composition.
+	NormNFC = "NFC"
+	// NormNFD define the Unicode canonical decomposition.
+	NormNFD = "NFD"
+	// NormNFKC define the Unicode compatibility composition.
+	NormNFKC = "NFKC"
+	// NormNFKD define the Unicode compatibility decomposition.
+	NormNFKD = "NFKD"
+)
+
+//
+// normForms map the name of Unicode normalization form to their value.
+//
+var normForms = map[string]norm.Form{
+	NormNFC:  norm.NFC,
+	NormNFD:  norm.NFD,
+	NormNFKC: norm.NFKC,
+	NormNFKD: norm.NFKD,
+}
+
+//
+// initTransform set the value transformation in field plan from metadata.
+//
+func (f *fieldPlan) initTransform(md MetadataInterface) {
+	tmd := getTransform(md)
+
+	switch tmd.GetTrim() {
+	case TrimLeft:
+		f.trimLeft = true
+	case TrimRight:
+		f.trimRight = true
+	case TrimBoth:
+		f.trimLeft = true
+		f.trimRight = true
+	}
+
+	f.caseMode = tmd.GetCase()
+	f.form, f.isNorm = normForms[tmd.GetNormalize()]
+	f.collapse = tmd.IsCollapseSpace()
+
+	f.transform = f.trimLeft || f.trimRight || f.caseMode != "" ||
+		f.isNorm || f.collapse
+}
+
+//
+// transformValue normalize the Unicode form, collapse white spaces, trim,
+// and convert the case of value, in that order.
+//
+// The returned value may be a new slice or the sub-slice of `v`, but `v` is
+// never modified.
+//
+func transformValue(f *fieldPlan, v []byte) []byte {
+	if f.isNorm {
+		v = f.form.Bytes(v)
+	}
+	if f.collapse {
+		v = collapseSpace(v)
+	}
+	if f.trimLeft {
+		v = bytes.TrimLeftFunc(v, unicode.IsSpace)
+	}
+	if f.trimRight {
+		v = bytes.TrimRightFunc(v, unicode.IsSpace)
+	}
+
+	switch f.caseMode {
+	case CaseUpper:
+		v = bytes.ToUpper(v)
+	case CaseLower:
+		v = bytes.ToLower(v)
+	case CaseTitle:
+		v = titleCase(v)
+	}
+
+	return v
+}
+
+//
+// titleCase convert the first letter of each word in `v` to upper case, and
+// the rest to lower case, where words are separated by white spaces.
+//
+func titleCase(v []byte) (out []byte) {
+	var buf [utf8.UTFMax]byte
+
+	out = make([]byte, 0, len(v))
+	isStart := true
+
+	for p := 0; p < len(v); {
+		r, size := utf8.DecodeRune(v[p:])
+		if r == utf8.RuneError && size == 1 {
+			// Invalid UTF-8 is copied as is.
+			out = append(out, v[p])
+			p++
+			isStart = false
+			continue
+		}
+		p += size
+
+		switch {
+		case unicode.IsSpace(r):
+			isStart = true
+		case isStart:
+			r = unicode.ToTitle(r)
+			isStart = false
+		default:
+			r = unicode.ToLower(r)
+		}
+
+		n := utf8.EncodeRune(buf[:], r)
+		out = append(out, buf[:n]...)
+	}
+
+	return out
+}
+
+//
+// collapseSpace replace each run of white spaces in `v` with single space.
+//
+func collapseSpace(v []byte) (out []byte) {
+	out = make([]byte, 0, len(v))
+
+	for p := 0; p < len(v); {
+		isSpace, size := isSpaceAt(v, p)
+		if !isSpace {
+			out = append(out, v[p:p+size]...)
+			p += size
+			continue
+		}
+
+		out = append(out, ' ')
+		p = parsingSkipSpace(v, p)
+	}
+
+	return out
+}
@@ -0,0 +1,234 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+)
+
+//
+// fieldPlan contain the compiled form of one input metadata.
+//
+// All string tokens in metadata (left-quote, right-quote, and separator) are
+// converted to slice of bytes only once, when the plan is created, so
+// ParseLine does not need to convert them again on each field of each line.
+//
+type fieldPlan struct {
+	// md is the metadata where this plan is compiled from.
+	md MetadataInterface
+	// lq is the left-quote.
+	lq []byte
+	// rq is the right-quote.
+	rq []byte
+	// sep is the separator.
+	sep []byte
+	// t is the type of column.
+	t int
+	// skip is true if the column will not be saved in row.
+	skip bool
+	// sepIsLq is true if separator is equal to left-quote.
+	sepIsLq bool
+	// sepIsSpace is true if separator is a single space.
+	sepIsSpace bool
+	// seps is the list of alternative separators.
+	seps [][]byte
+	// sepLongest is true if the longest alternative separator is used.
+	sepLongest bool
+	// sepIsWhitespace is true if separator is any run of white spaces.
+	sepIsWhitespace bool
+	// remainder is true if column take the rest of line.
+	remainder bool
+	// width is the number of characters in fixed width column.
+	width int
+	// start is the position of the first character of fixed width
+	// column, start from 1.
+	start int
+	// pad is the pad character of fixed width column.
+	pad string
+	// alignRight is true if value is aligned at the right side of fixed
+	// width column.
+	alignRight bool
+	// group is the index of named group in pattern with the same name as
+	// metadata, or -1 if not found.
+	group int
+	// transform is true if value need to be transformed before
+	// converted to record.
+	transform bool
+	// trimLeft is true if white spaces at the beginning of value will be
+	// removed.
+	trimLeft bool
+	// trimRight is true if white spaces at the end of value will be
+	// removed.
+	trimRight bool
+	// caseMode is the conversion of letter case.
+	caseMode string
+	// form is the Unicode normalization form, used if isNorm is true.
+	form   norm.Form
+	isNorm bool
+	// collapse is true if run of white spaces will be replaced with
+	// single space.
+	collapse bool
+	// isLocale is true if number in value need to be converted from
+	// metadata locale before converted to record.
+	isLocale bool
+	// decimalSep is the decimal separator in number.
+	decimalSep string
+	// groupingSep contain the grouping separators in number.
+	groupingSep string
+	// currency is the currency symbol in number.
+	currency string
+	// percent is true if number with percent sign is divided by 100.
+	percent bool
+}
+
+//
+// parsePlan contain list of compiled metadata that will be used to parse each
+// line.
+//
+type parsePlan struct {
+	fields []fieldPlan
+	// mds is the list of metadata where this plan is compiled from.
+	mds []MetadataInterface
+	// rowMds is the list of metadata of fields that will be saved in row,
+	// in the same order as records in row.
+	rowMds []MetadataInterface
+	// ncol is the number of fields that will be saved in row, or number
+	// of metadata with Skip is false.
+	ncol int
+	// maxFieldBytes is the maximum length of field value, copied from
+	// reader MaxFieldBytes.
+	maxFieldBytes int
+	// isFixed is true if one of the field is fixed width.
+	isFixed bool
+	// re is the regular expression to cut the value of all fields, if
+	// reader has Pattern.
+	re *regexp.Regexp
+	// logfmt is true if line is parsed as list of "key=value".
+	logfmt bool
+	// keepInvalid is true if value that can not be converted to the
+	// column type is saved as string record, instead of rejecting the
+	// line.
+	keepInvalid bool
+	// err is the error when setting the pattern, that will be returned
+	// when parsing the line.
+	err error
+}
+
+//
+// planner is an interface for reader that can cache their parse plan, so
+// ParseLine does not need to compile the input metadata on each call.
+//
+type planner interface {
+	parsePlan() *parsePlan
+}
+
+//
+// separatorCounter is implemented by reader that count the alternative
+// separators that is matched in each row.
+// The separators that is matched in the row is recorded in the list that is
+// owned by reader, not in the parse plan, so the plan can be shared.
+//
+type separatorCounter interface {
+	separatorMatches(n int) []int
+	countSeparators(plan *parsePlan)
+}
+
+//
+// newParsePlan compile list of metadata into parse plan.
+//
+func newParsePlan(mds []MetadataInterface) (plan *parsePlan) {
+	plan = &parsePlan{
+		fields: make([]fieldPlan, len(mds)),
+		mds:    mds,
+		rowMds: rowMetadata(mds),
+	}
+
+	for x, md := range mds {
+		f := &plan.fields[x]
+
+		f.md = md
+		f.lq = []byte(md.GetLeftQuote())
+		f.rq = []byte(md.GetRightQuote())
+		f.sep = []byte(md.GetSeparator())
+		f.t = md.GetType()
+		f.skip = md.GetSkip()
+		f.sepIsLq = md.GetSeparator() == md.GetLeftQuote()
+		f.sepIsSpace = md.GetSeparator() == " "
+		smd := getSeparators(md)
+		for _, sep := range smd.GetSeparators() {
+			f.seps = append(f.seps, []byte(sep))
+		}
+		f.sepLongest = smd.GetSeparatorMatch() == SeparatorMatchLongest
+		f.sepIsWhitespace = smd.GetSeparatorMode() == SeparatorModeWhitespace ||
+			md.GetSeparator() == SeparatorWhitespace
+		f.remainder = smd.IsRemainder()
+		if f.sepIsWhitespace {
+			f.sep = nil
+			f.seps = nil
+			f.sepIsLq = false
+			f.sepIsSpace = false
+		}
+		fmd := getFixedWidth(md)
+		f.width = fmd.GetWidth()
+		f.start = fmd.GetStart()
+		f.pad = fmd.GetPad()
+		f.alignRight = fmd.GetAlign() == AlignRight
+		f.initTransform(md)
+		f.initLocale(md)
+
+		if f.width > 0 {
+			plan.isFixed = true
+		}
+		if !f.skip {
+			plan.ncol++
+		}
+	}
+
+	return plan
+}
+
+//
+// setPattern set the regular expression that will be used to cut the value of
+// each field, using named group with the same name as metadata.
+// It will return an error if the metadata that is not skipped does not have
+// named group in pattern.
+//
+func (plan *parsePlan) setPattern(re *regexp.Regexp) (e error) {
+	plan.re = re
+	names := re.SubexpNames()
+
+	for x := range plan.fields {
+		f := &plan.fields[x]
+		f.group = -1
+
+		for y := 1; y < len(names); y++ {
+			if names[y] == f.md.GetName() {
+				f.group = y
+				break
+			}
+		}
+
+		if f.group < 0 && !f.skip && e == nil {
+			e = fmt.Errorf("dsv: Pattern does not have group %q",
+				f.md.GetName())
+		}
+	}
+
+	return e
+}
+
+//
+// getParsePlan return the cached parse plan from reader if its implement
+// planner, otherwise compile a new one from reader input metadata.
+//
+func getParsePlan(reader ReaderInterface) *parsePlan {
+	p, ok := reader.(planner)
+	if ok {
+		return p.parsePlan()
+	}
+	return newParsePlan(reader.GetInputMetadata())
+}
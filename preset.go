@@ -0,0 +1,240 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	// PresetApacheCombined define the preset for Apache combined log
+	// format.
+	PresetApacheCombined = "apache_combined"
+	// PresetNginx define the preset for Nginx default "combined" log
+	// format.
+	PresetNginx = "nginx"
+	// PresetSyslogRFC3164 define the preset for BSD syslog format, with
+	// optional priority.
+	PresetSyslogRFC3164 = "syslog_rfc3164"
+	// PresetSyslogRFC5424 define the preset for IETF syslog format.
+	PresetSyslogRFC5424 = "syslog_rfc5424"
+	// PresetLogfmt define the preset for "key=value" log format.
+	PresetLogfmt = "logfmt"
+)
+
+//
+// preset contain the pattern and metadata of common log format.
+//
+type preset struct {
+	pattern string
+	// logfmt is true if line is parsed as list of "key=value", where each
+	// key is mapped to metadata with the same name.
+	logfmt   bool
+	metadata []Metadata
+}
+
+//
+// presets contain list of builtin presets, by name.
+//
+// The number that is optional, for example size in Apache log which is "-"
+// if no bytes is sent, or priority and pid in BSD syslog, is empty if its not
+// present.
+//
+var presets = map[string]preset{
+	PresetApacheCombined: {
+		pattern: `^(?P<host>\S+) (?P<ident>\S+) (?P<user>\S+) ` +
+			`\[(?P<time>[^\]]+)\] ` +
+			`"(?:(?P<method>\S+) (?P<path>\S+) (?P<protocol>[^"]+)|` +
+			`[^"]*)" ` +
+			`(?P<status>\d{3}) (?:(?P<size>\d+)|-) ` +
+			`"(?P<referer>[^"]*)" "(?P<agent>[^"]*)"$`,
+		metadata: []Metadata{
+			{Name: "host"},
+			{Name: "ident"},
+			{Name: "user"},
+			{Name: "time"},
+			{Name: "method"},
+			{Name: "path"},
+			{Name: "protocol"},
+			{Name: "status", Type: "integer"},
+			{Name: "size", Type: "integer"},
+			{Name: "referer"},
+			{Name: "agent"},
+		},
+	},
+	PresetNginx: {
+		pattern: `^(?P<remote_addr>\S+) - (?P<remote_user>\S+) ` +
+			`\[(?P<time_local>[^\]]+)\] "(?P<request>[^"]*)" ` +
+			`(?P<status>\d{3}) (?P<body_bytes_sent>\d+) ` +
+			`"(?P<http_referer>[^"]*)" "(?P<http_user_agent>[^"]*)"$`,
+		metadata: []Metadata{
+			{Name: "remote_addr"},
+			{Name: "remote_user"},
+			{Name: "time_local"},
+			{Name: "request"},
+			{Name: "status", Type: "integer"},
+			{Name: "body_bytes_sent", Type: "integer"},
+			{Name: "http_referer"},
+			{Name: "http_user_agent"},
+		},
+	},
+	PresetSyslogRFC3164: {
+		pattern: `^(?:<(?P<priority>\d{1,3})>)?` +
+			`(?P<timestamp>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) ` +
+			`(?P<hostname>\S+) (?P<tag>[^:\[\s]+)` +
+			`(?:\[(?P<pid>\d+)\])?: ?(?P<message>.*)$`,
+		metadata: []Metadata{
+			{Name: "priority", Type: "integer"},
+			{Name: "timestamp"},
+			{Name: "hostname"},
+			{Name: "tag"},
+			{Name: "pid", Type: "integer"},
+			{Name: "message"},
+		},
+	},
+	PresetSyslogRFC5424: {
+		pattern: `^<(?P<priority>\d{1,3})>(?P<version>\d{1,2}) ` +
+			`(?P<timestamp>\S+) (?P<hostname>\S+) (?P<app_name>\S+) ` +
+			`(?P<procid>\S+) (?P<msgid>\S+) ` +
+			`(?P<structured_data>-|(?:\[(?:[^\]\\]|\\.)*\])+)` +
+			`(?: (?P<message>.*))?$`,
+		metadata: []Metadata{
+			{Name: "priority", Type: "integer"},
+			{Name: "version", Type: "integer"},
+			{Name: "timestamp"},
+			{Name: "hostname"},
+			{Name: "app_name"},
+			{Name: "procid"},
+			{Name: "msgid"},
+			{Name: "structured_data"},
+			{Name: "message"},
+		},
+	},
+	PresetLogfmt: {
+		logfmt: true,
+		metadata: []Metadata{
+			{Name: "time"},
+			{Name: "level"},
+			{Name: "msg"},
+		},
+	},
+}
+
+//
+// applyPreset set the reader Pattern and InputMetadata using the preset
+// with name Preset.
+// Pattern and InputMetadata that has been set in reader will not be
+// replaced.
+//
+func (reader *Reader) applyPreset() (e error) {
+	if reader.Preset == "" {
+		return nil
+	}
+
+	p, ok := presets[strings.ToLower(reader.Preset)]
+	if !ok {
+		return fmt.Errorf("dsv: Unknown preset %q", reader.Preset)
+	}
+
+	if len(reader.InputMetadata) == 0 {
+		reader.InputMetadata = make([]Metadata, len(p.metadata))
+		copy(reader.InputMetadata, p.metadata)
+	}
+	if reader.Pattern == "" {
+		reader.Pattern = p.pattern
+	}
+	reader.isLogfmt = p.logfmt
+
+	return nil
+}
+
+//
+// logfmtPair contain the key and value in "key=value" format.
+//
+type logfmtPair struct {
+	key []byte
+	v   []byte
+}
+
+//
+// parsingLogfmt parse the line as list of "key=value" separated by spaces.
+// The value can be quoted with double quote, where the double quote and
+// backslash inside it is escaped with backslash.
+// Key without value is parsed with empty value.
+//
+func parsingLogfmt(line []byte) (pairs []logfmtPair) {
+	p := 0
+
+	for {
+		p = parsingSkipSpace(line, p)
+		if p >= len(line) {
+			return pairs
+		}
+
+		pair := logfmtPair{}
+
+		start := p
+		for p < len(line) && line[p] != '=' && line[p] != ' ' &&
+			line[p] != '\t' {
+			p++
+		}
+		pair.key = line[start:p:p]
+
+		if p < len(line) && line[p] == '=' {
+			pair.v, p = parsingLogfmtValue(line, p+1)
+		}
+
+		pairs = append(pairs, pair)
+	}
+}
+
+//
+// parsingLogfmtValue parse the value in "key=value" starting from `startAt`.
+//
+func parsingLogfmtValue(line []byte, startAt int) (v []byte, p int) {
+	p = startAt
+
+	if p >= len(line) || line[p] != '"' {
+		for p < len(line) && line[p] != ' ' && line[p] != '\t' {
+			p++
+		}
+		return line[startAt:p:p], p
+	}
+
+	// Quoted value.
+	p++
+	start := p
+	x := bytes.IndexByte(line[p:], '"')
+	if x >= 0 && bytes.IndexByte(line[p:p+x], '\\') < 0 {
+		p += x
+		return line[start:p:p], p + 1
+	}
+
+	for ; p < len(line); p++ {
+		if line[p] == '\\' && p+1 < len(line) {
+			p++
+		} else if line[p] == '"' {
+			return v, p + 1
+		}
+		v = append(v, line[p])
+	}
+
+	return v, p
+}
+
+//
+// logfmtValue return the value of `key` in pairs, or nil if key is not
+// found.
+//
+func logfmtValue(pairs []logfmtPair, key string) []byte {
+	for _, pair := range pairs {
+		if string(pair.key) == key {
+			return pair.v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"encoding/json"
+	"github.com/shuLhan/tabula"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefProfileTopK default number of most frequent values in each
+	// column profile.
+	DefProfileTopK = 10
+	// DefProfileMaxDistinct default maximum number of distinct values
+	// that is counted exactly in each column.
+	DefProfileMaxDistinct = 10000
+)
+
+//
+// Profiler compute the statistics of each column in input file, in one pass.
+//
+type Profiler struct {
+	// TopK define the number of most frequent values in each column
+	// profile.
+	// Default is 10.
+	TopK int `json:"TopK"`
+	// MaxDistinct define the maximum number of distinct values that is
+	// counted exactly in each column. If column has more distinct values,
+	// the distinct count is estimated using HyperLogLog, and the
+	// frequency of new values is not counted, so the top-K values may
+	// be inaccurate.
+	// Default is 10000.
+	MaxDistinct int `json:"MaxDistinct"`
+}
+
+//
+// Profile contain the statistics of input file.
+//
+type Profile struct {
+	// Input is the input file.
+	Input string `json:"Input"`
+	// Rows is the number of rows that has been profiled.
+	Rows int `json:"Rows"`
+	// Filtered is the number of rows that does not match with reader
+	// Filter or dropped by reader transformers.
+	Filtered int `json:"Filtered"`
+	// Rejected is the number of lines that can not be parsed.
+	Rejected int `json:"Rejected"`
+	// Columns contain the profile of each column that is not skipped.
+	Columns []ColumnProfile `json:"Columns"`
+}
+
+//
+// ColumnProfile contain the statistics of one column.
+//
+type ColumnProfile struct {
+	// Name of column.
+	Name string `json:"Name"`
+	// Type of column in input metadata.
+	Type string `json:"Type"`
+	// Nulls is the number of empty values.
+	Nulls int `json:"Nulls"`
+	// Distinct is the number of distinct values, not including empty
+	// value.
+	Distinct int `json:"Distinct"`
+	// IsApprox is true if Distinct and TopK is estimated, because the
+	// number of distinct values is more than profiler MaxDistinct.
+	IsApprox bool `json:"IsApprox"`
+	// Min is the minimum value. For numeric column, only the values that
+	// conform to the column type is compared.
+	Min string `json:"Min"`
+	// Max is the maximum value.
+	Max string `json:"Max"`
+	// Mean is the average of numeric values.
+	Mean float64 `json:"Mean"`
+	// StdDev is the population standard deviation of numeric values.
+	StdDev float64 `json:"StdDev"`
+	// TopK contain the most frequent values, ordered by their count.
+	TopK []ValueCount `json:"TopK"`
+	// Length contain the distribution of value length, in characters.
+	Length LengthProfile `json:"Length"`
+	// Conforming is the number of non-empty values that conform to the
+	// column type.
+	Conforming int `json:"Conforming"`
+	// Conformance is the ratio of Conforming to the number of non-empty
+	// values, or zero if column does not have any value.
+	Conformance float64 `json:"Conformance"`
+}
+
+//
+// ValueCount contain a value and the number of times its found.
+//
+type ValueCount struct {
+	Value string `json:"Value"`
+	Count int    `json:"Count"`
+}
+
+//
+// LengthProfile contain the distribution of value length.
+//
+type LengthProfile struct {
+	Min  int     `json:"Min"`
+	Max  int     `json:"Max"`
+	Mean float64 `json:"Mean"`
+	// Buckets contain the number of values by their length, in range of
+	// power of two: 1, 2-3, 4-7, 8-15, and so on. Empty bucket is not
+	// included.
+	Buckets []LengthBucket `json:"Buckets"`
+}
+
+//
+// LengthBucket contain the number of values with length between Min and Max.
+//
+type LengthBucket struct {
+	Min   int `json:"Min"`
+	Max   int `json:"Max"`
+	Count int `json:"Count"`
+}
+
+//
+// columnProfiler accumulate the statistics of one column.
+//
+type columnProfiler struct {
+	cp          *ColumnProfile
+	t           int
+	maxDistinct int
+	counts      map[string]int
+	hll         *hyperLogLog
+	// nvalues is the number of non-empty values.
+	nvalues int
+	// nnum, mean, and m2 is used to compute the mean and variance
+	// using Welford's algorithm.
+	nnum     int
+	mean, m2 float64
+	minNum   float64
+	maxNum   float64
+	lenSum   int
+	buckets  []int
+}
+
+//
+// String return the profile in JSON format.
+//
+func (profile *Profile) String() string {
+	r, e := json.MarshalIndent(profile, "", "\t")
+	if nil != e {
+		return "dsv: " + e.Error()
+	}
+	return string(r)
+}
+
+//
+// Profile read all rows from reader, in batch of reader MaxRows, and return
+// the statistics of each column in reader InputMetadata.
+//
+// Value that can not be converted to the column type is not rejected, but
+// saved as string and counted as not conforming. Line that can not be
+// parsed is rejected as usual.
+// Rows that is parsed using layout is not profiled.
+//
+// The values in each row is mapped to columns by their position, so the
+// reader Transformers must keep the number and order of columns. Row with
+// different number of columns will stop the profiling with
+// ErrProfileRowShape.
+//
+// If reading or profiling is stopped by an error, the profile of rows that
+// has been read is returned along with the error.
+//
+func (profiler *Profiler) Profile(reader *Reader) (profile *Profile, e error) {
+	ds, ok := reader.GetDataset().(tabula.DatasetInterface)
+	if !ok || ds.GetMode() == tabula.DatasetModeColumns {
+		return nil, ErrProfileColumns
+	}
+
+	topK := profiler.TopK
+	if topK <= 0 {
+		topK = DefProfileTopK
+	}
+	maxDistinct := profiler.MaxDistinct
+	if maxDistinct <= 0 {
+		maxDistinct = DefProfileMaxDistinct
+	}
+
+	keepInvalid := reader.keepInvalid
+	reader.keepInvalid = true
+	defer func() {
+		reader.keepInvalid = keepInvalid
+	}()
+
+	plan := reader.parsePlan()
+
+	maxRows := reader.GetMaxRows()
+	if maxRows <= 0 {
+		reader.SetMaxRows(DefaultMaxRows)
+		defer reader.SetMaxRows(maxRows)
+	}
+
+	profile = &Profile{
+		Input: reader.GetInput(),
+	}
+
+	var cols []*columnProfiler
+
+	for _, md := range plan.mds {
+		if md.GetSkip() {
+			continue
+		}
+		profile.Columns = append(profile.Columns, ColumnProfile{
+			Name: md.GetName(),
+			Type: md.GetTypeName(),
+		})
+		cols = append(cols, &columnProfiler{
+			t:           md.GetType(),
+			maxDistinct: maxDistinct,
+			counts:      make(map[string]int),
+		})
+	}
+	for x := range cols {
+		cols[x].cp = &profile.Columns[x]
+	}
+
+	start := *reader.GetCheckpoint()
+
+profiling:
+	for {
+		_, eRead := Read(reader)
+
+		for _, row := range *ds.GetDataAsRows() {
+			if len(*row) != len(cols) {
+				e = ErrProfileRowShape
+				break profiling
+			}
+			for x, rec := range *row {
+				cols[x].add(rec)
+			}
+			profile.Rows++
+		}
+
+		if eRead == io.EOF {
+			break
+		}
+		if eRead != nil {
+			e = eRead
+			break
+		}
+	}
+
+	end := reader.GetCheckpoint()
+	profile.Filtered = end.Filtered - start.Filtered
+	profile.Rejected = end.Rejected - start.Rejected
+
+	for _, col := range cols {
+		col.finish(topK)
+	}
+
+	return profile, e
+}
+
+//
+// add the record value to column statistics.
+//
+func (col *columnProfiler) add(rec *tabula.Record) {
+	cp := col.cp
+	v := rec.String()
+
+	if rec.Type() == tabula.TString && strings.TrimSpace(v) == "" {
+		cp.Nulls++
+		return
+	}
+
+	col.nvalues++
+	col.addDistinct(v)
+
+	n := utf8.RuneCountInString(v)
+	col.lenSum += n
+	if col.nvalues == 1 || n < cp.Length.Min {
+		cp.Length.Min = n
+	}
+	if n > cp.Length.Max {
+		cp.Length.Max = n
+	}
+	if n > 0 {
+		b := bits.Len(uint(n)) - 1
+		for len(col.buckets) <= b {
+			col.buckets = append(col.buckets, 0)
+		}
+		col.buckets[b]++
+	}
+
+	if col.t == tabula.TString {
+		cp.Conforming++
+		if cp.Conforming == 1 || v < cp.Min {
+			cp.Min = v
+		}
+		if cp.Conforming == 1 || v > cp.Max {
+			cp.Max = v
+		}
+		return
+	}
+
+	if rec.Type() != col.t {
+		return
+	}
+
+	cp.Conforming++
+
+	f := rec.Float()
+	if rec.Type() == tabula.TInteger {
+		f = float64(rec.Integer())
+	}
+
+	col.nnum++
+	delta := f - col.mean
+	col.mean += delta / float64(col.nnum)
+	col.m2 += delta * (f - col.mean)
+
+	if col.nnum == 1 || f < col.minNum {
+		col.minNum = f
+		cp.Min = v
+	}
+	if col.nnum == 1 || f > col.maxNum {
+		col.maxNum = f
+		cp.Max = v
+	}
+}
+
+//
+// addDistinct count the value exactly, until the number of distinct values
+// is more than maxDistinct, and then estimate it using HyperLogLog.
+//
+func (col *columnProfiler) addDistinct(v string) {
+	if col.hll != nil {
+		col.hll.add(v)
+	}
+
+	_, ok := col.counts[v]
+	if ok {
+		col.counts[v]++
+		return
+	}
+	if col.hll != nil {
+		return
+	}
+	if len(col.counts) < col.maxDistinct {
+		col.counts[v] = 1
+		return
+	}
+
+	col.cp.IsApprox = true
+	col.hll = &hyperLogLog{}
+	for k := range col.counts {
+		col.hll.add(k)
+	}
+	col.hll.add(v)
+}
+
+//
+// finish compute the final statistics of column.
+//
+func (col *columnProfiler) finish(topK int) {
+	cp := col.cp
+
+	if col.hll != nil {
+		cp.Distinct = col.hll.count()
+	} else {
+		cp.Distinct = len(col.counts)
+	}
+
+	if col.nnum > 0 {
+		cp.Mean = col.mean
+		cp.StdDev = math.Sqrt(col.m2 / float64(col.nnum))
+	}
+
+	if col.nvalues > 0 {
+		cp.Length.Mean = float64(col.lenSum) / float64(col.nvalues)
+		cp.Conformance = float64(cp.Conforming) / float64(col.nvalues)
+	}
+
+	for b, n := range col.buckets {
+		if n == 0 {
+			continue
+		}
+		cp.Length.Buckets = append(cp.Length.Buckets, LengthBucket{
+			Min:   1 << uint(b),
+			Max:   1<<uint(b+1) - 1,
+			Count: n,
+		})
+	}
+
+	cp.TopK = make([]ValueCount, 0, len(col.counts))
+	for v, n := range col.counts {
+		cp.TopK = append(cp.TopK, ValueCount{Value: v, Count: n})
+	}
+	sort.Slice(cp.TopK, func(a, b int) bool {
+		if cp.TopK[a].Count != cp.TopK[b].Count {
+			return cp.TopK[a].Count > cp.TopK[b].Count
+		}
+		return cp.TopK[a].Value < cp.TopK[b].Value
+	})
+	if len(cp.TopK) > topK {
+		cp.TopK = cp.TopK[:topK]
+	}
+}
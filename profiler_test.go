@@ -0,0 +1,213 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv_test
+
+import (
+	"encoding/json"
+	"github.com/shuLhan/dsv"
+	"github.com/shuLhan/tabula"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"1,alice,10\n2,bob,20\nx,alice,\n"+
+			"4,,30.5\n5,carol,abc\n6\n")
+
+	cases := []struct {
+		desc        string
+		maxDistinct int
+		isApprox    bool
+	}{{
+		desc: "With exact distinct count",
+	}, {
+		desc:        "With estimated distinct count",
+		maxDistinct: 2,
+		isApprox:    true,
+	}}
+
+	expID := dsv.ColumnProfile{
+		Name:     "id",
+		Type:     "integer",
+		Distinct: 5,
+		Min:      "1",
+		Max:      "5",
+		Mean:     3,
+		StdDev:   math.Sqrt(2.5),
+		TopK: []dsv.ValueCount{
+			{Value: "1", Count: 1},
+			{Value: "2", Count: 1},
+		},
+		Length: dsv.LengthProfile{
+			Min:  1,
+			Max:  1,
+			Mean: 1,
+			Buckets: []dsv.LengthBucket{
+				{Min: 1, Max: 1, Count: 5},
+			},
+		},
+		Conforming:  4,
+		Conformance: 0.8,
+	}
+
+	expName := dsv.ColumnProfile{
+		Name:     "name",
+		Type:     "string",
+		Nulls:    1,
+		Distinct: 3,
+		Min:      "alice",
+		Max:      "carol",
+		TopK: []dsv.ValueCount{
+			{Value: "alice", Count: 2},
+			{Value: "bob", Count: 1},
+		},
+		Length: dsv.LengthProfile{
+			Min:  3,
+			Max:  5,
+			Mean: 4.5,
+			Buckets: []dsv.LengthBucket{
+				{Min: 2, Max: 3, Count: 1},
+				{Min: 4, Max: 7, Count: 3},
+			},
+		},
+		Conforming:  4,
+		Conformance: 1,
+	}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		reader := &dsv.Reader{
+			Input:    input,
+			Rejected: filepath.Join(dir, "rejected.dat"),
+			MaxRows:  2,
+			InputMetadata: []dsv.Metadata{{
+				Name:      "id",
+				Type:      "integer",
+				Separator: ",",
+			}, {
+				Name:      "name",
+				Separator: ",",
+			}, {
+				Name: "score",
+				Type: "real",
+			}},
+		}
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		profiler := &dsv.Profiler{
+			TopK:        2,
+			MaxDistinct: c.maxDistinct,
+		}
+
+		profile, e := profiler.Profile(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, 5, profile.Rows, true)
+		assert(t, 1, profile.Rejected, true)
+		assert(t, 3, len(profile.Columns), true)
+		expID.IsApprox = c.isApprox
+		expName.IsApprox = c.isApprox
+
+		assert(t, expID, profile.Columns[0], true)
+		assert(t, expName, profile.Columns[1], true)
+
+		score := profile.Columns[2]
+
+		assert(t, 1, score.Nulls, true)
+		assert(t, 4, score.Distinct, true)
+		assert(t, 3, score.Conforming, true)
+		assert(t, 0.75, score.Conformance, true)
+		assert(t, true, math.Abs(score.Mean-60.5/3) < 1e-9, true)
+
+		// Profile can be converted back from its JSON format.
+		var got dsv.Profile
+
+		e = json.Unmarshal([]byte(profile.String()), &got)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, profile, &got, true)
+
+		// Reader still reject invalid value after profiling.
+		assert(t, 2, reader.GetMaxRows(), true)
+
+		e = reader.Open()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		reader.SetMaxRows(-1)
+
+		_, e = dsv.Read(reader)
+
+		assert(t, 3, reader.GetCheckpoint().Rejected, true)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+func TestProfilerRowShape(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "1,a\n2,b\n3,c\n")
+
+	// drop the second column, after the first two rows.
+	drop := dsv.RowTransformerFunc(func(row *tabula.Row,
+		md []dsv.MetadataInterface,
+	) (
+		[]*tabula.Row, error,
+	) {
+		if (*row)[0].Integer() <= 2 {
+			return []*tabula.Row{row}, nil
+		}
+		return []*tabula.Row{{(*row)[0]}}, nil
+	})
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		MaxRows:  2,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name: "name",
+		}},
+		Transformers: dsv.RowTransformers{drop},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	profiler := &dsv.Profiler{}
+
+	profile, e := profiler.Profile(reader)
+
+	assert(t, dsv.ErrProfileRowShape, e, true)
+
+	// The profile of rows that has been read is returned.
+	assert(t, 2, profile.Rows, true)
+	assert(t, 2, profile.Columns[0].Distinct, true)
+	assert(t, "2", profile.Columns[0].Max, true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
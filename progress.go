@@ -0,0 +1,112 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"fmt"
+	"time"
+)
+
+//
+// ProgressFunc is the function that is called by reader to report the
+// progress of reading input file.
+//
+type ProgressFunc func(stats Stats)
+
+//
+// logProgress log the statistics using reader logger.
+//
+func (reader *Reader) logProgress(stats Stats) {
+	reader.GetLogger().Info("progress", "bytes", stats.Bytes,
+		"lines", stats.Lines, "read", stats.RowsRead,
+		"written", stats.RowsWritten, "filtered", stats.RowsFiltered,
+		"rejected", stats.RowsRejected, "elapsed", stats.Elapsed,
+		"rows/s", fmt.Sprintf("%.1f", stats.RowsPerSecond()))
+}
+
+//
+// resetStats set the statistics start from the current checkpoint.
+//
+func (reader *Reader) resetStats() {
+	now := time.Now()
+
+	reader.statsMu.Lock()
+	reader.statsStart = reader.checkpoint
+	reader.statsTime = now
+	reader.stats = Stats{}
+	reader.statsMu.Unlock()
+
+	reader.progressTime = now
+	reader.progressRows = 0
+}
+
+//
+// Stats return the statistics of reader since the input file is opened.
+// The statistics is updated on each progress report and at the end of each
+// Read, so its safe to be called from other goroutine while reading.
+//
+func (reader *Reader) Stats() Stats {
+	reader.statsMu.Lock()
+	stats := reader.stats
+	reader.statsMu.Unlock()
+	return stats
+}
+
+//
+// ReportProgress update the reader statistics and call the Progress function,
+// if the number of rows or the time since the last report has reached
+// ProgressRows or ProgressInterval.
+// If `isEnd` is true, the statistics is always updated, but Progress is only
+// called if its due.
+//
+func (reader *Reader) ReportProgress(isEnd bool) {
+	var now time.Time
+
+	cp := &reader.checkpoint
+	nrows := cp.Rows + cp.Filtered + cp.Rejected
+	isDue := reader.ProgressRows > 0 &&
+		nrows-reader.progressRows >= reader.ProgressRows
+
+	if reader.ProgressInterval > 0 {
+		now = time.Now()
+		interval := time.Duration(reader.ProgressInterval) *
+			time.Millisecond
+		if now.Sub(reader.progressTime) >= interval {
+			isDue = true
+		}
+	}
+
+	if !isDue && !isEnd {
+		return
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	start := &reader.statsStart
+
+	reader.statsMu.Lock()
+	reader.stats.Bytes = cp.Offset - start.Offset
+	reader.stats.Lines = cp.Line - start.Line
+	reader.stats.RowsRead = cp.Rows - start.Rows
+	reader.stats.RowsFiltered = cp.Filtered - start.Filtered
+	reader.stats.RowsRejected = cp.Rejected - start.Rejected
+	reader.stats.Elapsed = now.Sub(reader.statsTime)
+	stats := reader.stats
+	reader.statsMu.Unlock()
+
+	if !isDue {
+		return
+	}
+
+	reader.progressRows = nrows
+	reader.progressTime = now
+
+	if reader.Progress != nil {
+		reader.Progress(stats)
+	} else {
+		reader.logProgress(stats)
+	}
+}
@@ -6,10 +6,15 @@ package dsv
 
 import (
 	"bufio"
+	"bytes"
 	"github.com/shuLhan/tabula"
-	"log"
+	"io"
 	"os"
+	"reflect"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -111,14 +116,217 @@ type Reader struct {
 	// "matrix" mode is where each record saved in their own row and column.
 	//
 	DatasetMode string `json:"DatasetMode"`
+	// MMap if its true, the input file will be mapped into memory and
+	// each line will be parsed directly from the mapped memory, instead of
+	// copied to buffer.
+	// If input file can not be mapped, for example a pipe or a device,
+	// reader will fallback to buffered reading.
+	// Default is false.
+	MMap bool `json:"MMap"`
+	// PersistCheckpoint if its true, the reader checkpoint will be saved
+	// to file on each SaveCheckpoint, and when reader is initialized the
+	// checkpoint will be loaded from file and input will be resumed from
+	// the last saved position.
+	// The checkpoint file is the input file name with ".checkpoint"
+	// extension.
+	// Default is false.
+	PersistCheckpoint bool `json:"PersistCheckpoint"`
+	// Follow if its true, reader will not stop when reaching the end of
+	// input file, but wait for more data to be appended to it, like
+	// "tail -f".
+	// If input file is truncated, reader will continue reading from the
+	// beginning of file. If input file is rotated (the file name refer to
+	// different file), reader will open the new file and read it from the
+	// beginning.
+	// Follow will disable MMap.
+	// Default is false.
+	Follow bool `json:"Follow"`
+	// FollowInterval define the time, in milliseconds, to wait before
+	// checking for new data in input file, when Follow is true.
+	// Default is 1000 milliseconds.
+	FollowInterval int `json:"FollowInterval"`
+	// MaxLineBytes define the maximum length of line, in bytes, excluding
+	// the end-of-line.
+	// Line longer than this will be rejected and only the first
+	// MaxLineBytes of line will be saved in rejected file.
+	// Default is 0, no limit.
+	MaxLineBytes int `json:"MaxLineBytes"`
+	// MaxRecordLines define the maximum number of lines in one record,
+	// for example when the value in quote contain new line or when the
+	// right-quote is missing.
+	// Record with more lines than this will be rejected and reader will
+	// continue reading from the second line of record.
+	// MaxLineBytes alone does not limit the size of record, set
+	// MaxRecordLines or MaxFieldBytes to limit it.
+	// Default is 0, no limit.
+	MaxRecordLines int `json:"MaxRecordLines"`
+	// MaxFieldBytes define the maximum length of field value, in bytes.
+	// Record that contain field longer than this will be rejected, as
+	// soon as the value reach the limit, and reader will continue
+	// reading from the second line of record.
+	// Default is 0, no limit.
+	MaxFieldBytes int `json:"MaxFieldBytes"`
+	// Comment define the prefix of comment line, for example "#" or "//".
+	// Line that start with this prefix, after leading white spaces is
+	// removed, will be skipped.
+	// Default is empty, no comment line.
+	Comment string `json:"Comment"`
+	// KeepComments if its true, the comment lines will be saved in reader
+	// and can be retrieved using GetComments after each Read.
+	// Default is false.
+	KeepComments bool `json:"KeepComments"`
+	// SkipBlankLines if its false, empty or white space only line will
+	// not be skipped, but parsed as a record.
+	// Default is true.
+	SkipBlankLines *bool `json:"SkipBlankLines"`
+	// SkipFooter define the number of lines at the end of input file that
+	// will be skipped, not including the trailer line.
+	// SkipFooter is ignored if Follow is true.
+	// Default is 0.
+	SkipFooter int `json:"SkipFooter"`
+	// Trailer define the format of trailer line, which contain the number
+	// of records or the sum of column values.
+	// Trailer line is not saved in dataset, and its values are verified
+	// when reader reach the end of input file.
+	// Default is nil, no trailer.
+	Trailer *Trailer `json:"Trailer"`
+	// Layouts define the format of each record type, for input file that
+	// contain multiple record types.
+	// Each layout is selected by line prefix or by the value of the first
+	// field, and the rows are saved in the layout dataset.
+	// Line that does not match with any layout is parsed using
+	// InputMetadata.
+	// Default is empty.
+	Layouts []Layout `json:"Layouts"`
+	// Pattern define the regular expression to parse each line, instead
+	// of using separator and quotes.
+	// Each named group in pattern, for example "(?P<host>\\S+)", is
+	// mapped to the input metadata with the same name.
+	// Line that does not match with pattern will be rejected.
+	// Column in optional group that is not matched is empty.
+	// Default is empty.
+	Pattern string `json:"Pattern"`
+	// Preset define the name of builtin format for common log files:
+	// "apache_combined", "nginx", "syslog_rfc3164", "syslog_rfc5424", or
+	// "logfmt".
+	// Preset set the Pattern and InputMetadata, if its empty.
+	// Default is empty.
+	Preset string `json:"Preset"`
+	// Filter define the expression that is evaluated on each row, for
+	// example `status == "ERROR" && latency > 500`.
+	// Row that does not match with filter is not saved in dataset and not
+	// rejected, but counted in checkpoint Filtered.
+	// Default is empty, all rows are saved.
+	Filter string `json:"Filter"`
+	// Transformers define the chain of row transformers that is applied
+	// to each row after its parsed and filtered, and before its saved in
+	// dataset.
+	// Row that failed to be transformed is rejected.
+	Transformers RowTransformers `json:"-"`
+	// ProgressRows define the number of rows, including the filtered and
+	// rejected rows, between each progress report.
+	// Default is 0, progress is not reported by number of rows.
+	ProgressRows int `json:"ProgressRows"`
+	// ProgressInterval define the time, in milliseconds, between each
+	// progress report.
+	// Default is 0, progress is not reported by time.
+	ProgressInterval int `json:"ProgressInterval"`
+	// Progress define the function that is called on each progress
+	// report, with the statistics since the input file is opened.
+	// Default is nil, the statistics is logged using Logger, as info
+	// event.
+	Progress ProgressFunc `json:"-"`
+	// Logger define the logger for reader events, like loading config,
+	// opening and closing file, and rejecting line.
+	// Default is nil, only the warning and error events is printed,
+	// to standard error.
+	Logger Logger `json:"-"`
 	// fRead is read descriptor.
 	fRead *os.File
 	// fReject is reject descriptor.
 	fReject *os.File
 	// bufRead is a buffer for working with input file.
 	bufRead *bufio.Reader
+	// mmap contain the content of input file if MMap is true.
+	mmap []byte
+	// mmapOff is the current read position in mmap.
+	mmapOff int
 	// bufReject is a buffer for working with rejected file.
 	bufReject *bufio.Writer
+	// plan is the compiled input metadata for parsing each line.
+	plan *parsePlan
+	// planMetadata is the copy of InputMetadata where plan is compiled
+	// from, to check if the input metadata has been changed on Reset.
+	planMetadata []Metadata
+	// keepInvalid is true if value that can not be converted to the
+	// column type is saved as string instead of rejected, used by
+	// Profiler.
+	keepInvalid bool
+	// sepCounts contain the number of times each alternative separator
+	// is matched in each column, since the last Reset.
+	sepCounts map[string]map[string]int
+	// sepMatches contain the index of alternative separator that is
+	// matched in each field of the last parsed row, or -1 if none is
+	// matched.
+	sepMatches []int
+	// re is the compiled Pattern.
+	re *regexp.Regexp
+	// isLogfmt is true if Preset is "logfmt".
+	isLogfmt bool
+	// filter is the compiled Filter.
+	filter *expr
+	// statsMu protect the stats from concurrent access.
+	statsMu sync.Mutex
+	// stats contain the last statistics of reader.
+	stats Stats
+	// statsStart is the checkpoint when input file is opened.
+	statsStart Checkpoint
+	// statsTime is the time when input file is opened.
+	statsTime time.Time
+	// progressRows is the number of rows on the last progress report.
+	progressRows int
+	// progressTime is the time of the last progress report.
+	progressTime time.Time
+	// checkpoint contain the current position and counters of reader.
+	checkpoint Checkpoint
+	// index contain the position of records in input file, used by
+	// SeekRow.
+	index *Index
+	// partial contain the incomplete line that has been read from
+	// buffer.
+	partial []byte
+	// lineSize is the number of bytes that has been read for the current
+	// line, including the discarded bytes if line is too long.
+	lineSize int
+	// isTooLong is true if the current line is longer than MaxLineBytes.
+	isTooLong bool
+	// recordLines is the number of lines in the current record.
+	recordLines int
+	// recordNext is the position after the first line of current
+	// record, used to continue reading when record is too long.
+	recordNext Checkpoint
+	// recordRest contain the lines after the first line of current
+	// record.
+	recordRest []footerLine
+	// pending contain the lines of rewinded record, that will be read
+	// again before reading the next line from input.
+	pending []footerLine
+	// comments contain the comment lines in the last Read, if
+	// KeepComments is true.
+	comments []string
+	// isIdle is true if reader has reached end of input file and has
+	// notified the caller, when Follow is true.
+	isIdle bool
+	// followStop is a channel to stop following the input file.
+	followStop chan bool
+	// isStopped is true if following the input file has been stopped.
+	isStopped bool
+	// followSkip is the number of lines that will be skipped in input
+	// file after its truncated or rotated.
+	followSkip int
+	// footer contain the lines that has been read ahead, if SkipFooter
+	// is greater than zero.
+	footer []footerLine
 }
 
 //
@@ -153,13 +361,15 @@ func NewReader(config string, dataset interface{}) (reader *Reader, e error) {
 //
 // (1) Check if dataset is not empty.
 // (2) Read config file.
-// (3) Set reader object default value.
+// (3) Set reader object default value, and apply the preset.
 // (4) Check if output mode is valid and initialize it if valid.
-// (5) Check and initialize metadata and columns attributes.
+// (5) Check and initialize metadata and columns attributes, and compile
+//     them into parse plan, including the trailer and layouts metadata.
 // (6) Check if Input is name only without path, so we can prefix it with
 //     config path.
-// (7) Open rejected file.
-// (8) Open input file.
+// (7) Load the last checkpoint, if PersistCheckpoint is true.
+// (8) Open rejected file.
+// (9) Open input file.
 //
 func (reader *Reader) Init(fcfg string, dataset interface{}) (e error) {
 	// (1)
@@ -183,17 +393,37 @@ func (reader *Reader) Init(fcfg string, dataset interface{}) (e error) {
 		if e != nil {
 			return e
 		}
+
+		reader.GetLogger().Debug("config loaded", "config", fcfg,
+			"input", reader.Input)
 	}
 
 	// (3)
 	reader.SetDefault()
 
+	e = reader.applyPreset()
+	if e != nil {
+		return e
+	}
+
 	// (4)
 	reader.SetDatasetMode(reader.GetDatasetMode())
 
 	// (5)
 	ds := dataset.(tabula.DatasetInterface)
 	md := reader.GetInputMetadata()
+
+	e = checkMetadata(md)
+	if e != nil {
+		return e
+	}
+	for x := range reader.Layouts {
+		e = checkMetadata(reader.Layouts[x].GetInputMetadata())
+		if e != nil {
+			return e
+		}
+	}
+
 	for i := range md {
 		md[i].Init()
 
@@ -208,18 +438,68 @@ func (reader *Reader) Init(fcfg string, dataset interface{}) (e error) {
 			ds.PushColumn(col)
 		}
 	}
+	e = checkInputMetadata(md)
+	if e != nil {
+		return e
+	}
+
+	if reader.Pattern != "" {
+		reader.re, e = regexp.Compile(reader.Pattern)
+		if e != nil {
+			return e
+		}
+	}
+
+	e = reader.compilePlan()
+	if e != nil {
+		return e
+	}
+
+	if reader.Filter != "" {
+		reader.filter, e = compileExpr(reader.Filter)
+		if e != nil {
+			return e
+		}
+		e = reader.filter.bind(reader.plan.mds)
+		if e != nil {
+			return e
+		}
+	}
+
+	if reader.Trailer != nil {
+		e = reader.Trailer.init(md)
+		if e != nil {
+			return e
+		}
+	}
+	for x := range reader.Layouts {
+		reader.Layouts[x].init(ds.GetMode(), reader.MaxFieldBytes)
+
+		e = checkInputMetadata(reader.Layouts[x].GetInputMetadata())
+		if e != nil {
+			return e
+		}
+	}
 
 	// (6)
 	reader.SetInput(ConfigCheckPath(reader, reader.GetInput()))
 	reader.SetRejected(ConfigCheckPath(reader, reader.GetRejected()))
 
 	// (7)
+	if reader.PersistCheckpoint {
+		e = reader.loadCheckpoint()
+		if nil != e {
+			return
+		}
+	}
+
+	// (8)
 	e = reader.OpenRejected()
 	if nil != e {
 		return
 	}
 
-	// (8)
+	// (9)
 	e = reader.OpenInput()
 	if nil != e {
 		return
@@ -241,6 +521,9 @@ func (reader *Reader) SetDefault() {
 	if "" == strings.TrimSpace(reader.DatasetMode) {
 		reader.DatasetMode = DefDatasetMode
 	}
+	if reader.FollowInterval <= 0 {
+		reader.FollowInterval = DefFollowInterval
+	}
 	if nil == reader.dataset {
 		reader.dataset = &tabula.Dataset{}
 	}
@@ -248,7 +531,8 @@ func (reader *Reader) SetDefault() {
 
 //
 // CopyConfig copy configuration from other reader object not including data
-// and metadata.
+// and input metadata. The layouts and trailer is copied including their
+// metadata, without their compiled state.
 //
 func (reader *Reader) CopyConfig(src *Reader) {
 	reader.ConfigPath = src.GetConfigPath()
@@ -258,6 +542,45 @@ func (reader *Reader) CopyConfig(src *Reader) {
 	reader.Rejected = src.GetRejected()
 	reader.MaxRows = src.GetMaxRows()
 	reader.DatasetMode = src.GetDatasetMode()
+	reader.MMap = src.IsMMap()
+	reader.PersistCheckpoint = src.PersistCheckpoint
+	reader.Follow = src.Follow
+	reader.FollowInterval = src.FollowInterval
+	reader.MaxLineBytes = src.MaxLineBytes
+	reader.MaxRecordLines = src.MaxRecordLines
+	reader.MaxFieldBytes = src.MaxFieldBytes
+	reader.Comment = src.GetComment()
+	reader.Pattern = src.Pattern
+	reader.Preset = src.Preset
+	reader.Filter = src.Filter
+	reader.Transformers = src.Transformers
+	reader.ProgressRows = src.ProgressRows
+	reader.ProgressInterval = src.ProgressInterval
+	reader.Progress = src.Progress
+	reader.Logger = src.Logger
+	reader.KeepComments = src.KeepComments
+	reader.SetSkipBlankLines(src.IsSkipBlankLines())
+	reader.SkipFooter = src.SkipFooter
+	reader.Trailer = nil
+	if src.Trailer != nil {
+		reader.Trailer = &Trailer{
+			Pattern:   src.Trailer.Pattern,
+			Metadata:  append([]Metadata(nil), src.Trailer.Metadata...),
+			Count:     src.Trailer.Count,
+			Sum:       src.Trailer.Sum,
+			SumColumn: src.Trailer.SumColumn,
+		}
+	}
+	reader.Layouts = nil
+	for _, layout := range src.Layouts {
+		reader.Layouts = append(reader.Layouts, Layout{
+			Name:   layout.Name,
+			Prefix: layout.Prefix,
+			Value:  layout.Value,
+			InputMetadata: append([]Metadata(nil),
+				layout.InputMetadata...),
+		})
+	}
 }
 
 //
@@ -295,6 +618,54 @@ func (reader *Reader) IsTrimSpace() bool {
 	return reader.TrimSpace
 }
 
+//
+// IsMMap return value of MMap option.
+//
+func (reader *Reader) IsMMap() bool {
+	return reader.MMap
+}
+
+//
+// GetComment return the prefix of comment line.
+//
+func (reader *Reader) GetComment() string {
+	return reader.Comment
+}
+
+//
+// PushComment save the comment line, if KeepComments is true.
+//
+func (reader *Reader) PushComment(line []byte) {
+	if reader.KeepComments {
+		reader.comments = append(reader.comments, string(line))
+	}
+}
+
+//
+// GetComments return the comment lines that has been read by the last Read.
+//
+func (reader *Reader) GetComments() []string {
+	return reader.comments
+}
+
+//
+// IsSkipBlankLines return value of SkipBlankLines option. If its not set,
+// it will return true.
+//
+func (reader *Reader) IsSkipBlankLines() bool {
+	if reader.SkipBlankLines == nil {
+		return true
+	}
+	return *reader.SkipBlankLines
+}
+
+//
+// SetSkipBlankLines set the SkipBlankLines option.
+//
+func (reader *Reader) SetSkipBlankLines(skip bool) {
+	reader.SkipBlankLines = &skip
+}
+
 //
 // GetRejected return name of rejected file.
 //
@@ -314,6 +685,7 @@ func (reader *Reader) SetRejected(path string) {
 //
 func (reader *Reader) AddInputMetadata(md *Metadata) {
 	reader.InputMetadata = append(reader.InputMetadata, *md)
+	reader.plan = nil
 	ds := reader.dataset.(tabula.DatasetInterface)
 	ds.AddColumn(md.GetType(), md.GetName(), md.GetValueSpace())
 }
@@ -324,6 +696,7 @@ func (reader *Reader) AddInputMetadata(md *Metadata) {
 func (reader *Reader) AppendMetadata(mdi MetadataInterface) {
 	md := mdi.(*Metadata)
 	reader.InputMetadata = append(reader.InputMetadata, *md)
+	reader.plan = nil
 }
 
 //
@@ -338,6 +711,43 @@ func (reader *Reader) GetInputMetadata() []MetadataInterface {
 	return md
 }
 
+//
+// compilePlan compile the input metadata into parse plan, and keep the copy
+// of input metadata to check if its changed on Reset.
+// It will return an error if reader has pattern and one of metadata does not
+// have named group in pattern.
+//
+func (reader *Reader) compilePlan() (e error) {
+	reader.plan = newParsePlan(reader.GetInputMetadata())
+	reader.plan.logfmt = reader.isLogfmt
+	reader.planMetadata = copyMetadata(reader.InputMetadata)
+
+	if reader.re != nil {
+		e = reader.plan.setPattern(reader.re)
+		reader.plan.err = e
+	}
+
+	return e
+}
+
+//
+// parsePlan return the compiled input metadata. The plan is created on Init
+// and recompiled only if the input metadata has been changed using
+// AddInputMetadata or AppendMetadata, or on Reset if the input metadata has
+// been changed through the pointer returned by GetInputMetadataAt.
+//
+// If the plan is recompiled here and the pattern does not match with input
+// metadata, the error is returned when parsing the line.
+//
+func (reader *Reader) parsePlan() *parsePlan {
+	if reader.plan == nil {
+		_ = reader.compilePlan()
+	}
+	reader.plan.maxFieldBytes = reader.MaxFieldBytes
+	reader.plan.keepInvalid = reader.keepInvalid
+	return reader.plan
+}
+
 //
 // GetInputMetadataAt return pointer to metadata at index 'idx'.
 //
@@ -396,13 +806,51 @@ func (reader *Reader) GetNColumnIn() int {
 //
 // OpenInput open the input file, metadata must have been initialize.
 //
+// If MMap is true and Follow is false, the input file will be mapped into
+// memory. If mapping failed or not possible, it will fallback to buffered
+// reader.
+//
+// If reader has a checkpoint, the input will be resumed from the checkpoint
+// offset, otherwise the first n lines defined in Skip will be skipped.
+//
 func (reader *Reader) OpenInput() (e error) {
 	reader.fRead, e = os.OpenFile(reader.Input, os.O_RDONLY, 0600)
 	if nil != e {
 		return e
 	}
 
-	reader.bufRead = bufio.NewReader(reader.fRead)
+	reader.mmap = nil
+	reader.mmapOff = 0
+	reader.partial = nil
+	reader.lineSize = 0
+	reader.isTooLong = false
+	reader.isIdle = false
+	reader.isStopped = false
+	reader.followStop = make(chan bool, 1)
+	reader.footer = nil
+	reader.recordRest = nil
+	reader.pending = nil
+	if reader.Trailer != nil {
+		reader.Trailer.reset()
+	}
+	reader.resetStats()
+
+	if reader.MMap && !reader.Follow {
+		reader.mmap, e = mmapFile(reader.fRead)
+		if e != nil {
+			reader.mmap = nil
+		}
+	}
+	if reader.mmap == nil {
+		reader.bufRead = bufio.NewReader(reader.fRead)
+	}
+
+	reader.GetLogger().Info("input opened", "path", reader.Input,
+		"offset", reader.checkpoint.Offset, "mmap", reader.mmap != nil)
+
+	if reader.checkpoint.Offset > 0 {
+		return reader.SeekInput(reader.checkpoint.Offset)
+	}
 
 	// Skip lines
 	if reader.GetSkip() > 0 {
@@ -418,21 +866,29 @@ func (reader *Reader) OpenInput() (e error) {
 
 //
 // OpenRejected open rejected file, for saving unparseable line.
+// If reader has a checkpoint, the rejected file will be opened in append
+// mode, to keep the lines rejected before checkpoint.
 //
 func (reader *Reader) OpenRejected() (e error) {
-	reader.fReject, e = os.OpenFile(reader.Rejected,
-		os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	flag := os.O_CREATE | os.O_TRUNC | os.O_WRONLY
+	if reader.checkpoint.Offset > 0 {
+		flag = os.O_CREATE | os.O_APPEND | os.O_WRONLY
+	}
+
+	reader.fReject, e = os.OpenFile(reader.Rejected, flag, 0600)
 	if nil != e {
 		return e
 	}
 
 	reader.bufReject = bufio.NewWriter(reader.fReject)
 
+	reader.GetLogger().Debug("rejected opened", "path", reader.Rejected)
+
 	return nil
 }
 
 //
-// Open input and rejected file.
+// Open input and rejected file, and read the input from the beginning.
 //
 func (reader *Reader) Open() (e error) {
 	// do not let file descriptor leaked
@@ -441,6 +897,8 @@ func (reader *Reader) Open() (e error) {
 		return
 	}
 
+	reader.checkpoint = Checkpoint{}
+
 	e = reader.OpenInput()
 	if e != nil {
 		return
@@ -457,10 +915,13 @@ func (reader *Reader) Open() (e error) {
 //
 func (reader *Reader) SkipLines() (e error) {
 	for i := 0; i < reader.Skip; i++ {
-		_, e = reader.ReadLine()
-
+		_, e = reader.readLine(false)
+		if e == ErrLineTooLong {
+			e = nil
+		}
 		if nil != e {
-			log.Print("dsv: ", e)
+			reader.GetLogger().Error("skip lines failed",
+				"path", reader.Input, "error", e)
 			return
 		}
 	}
@@ -469,13 +930,36 @@ func (reader *Reader) SkipLines() (e error) {
 
 //
 // Reset all variables for next read operation. Number of rows will be 0, and
-// Rows will be empty again.
+// Rows, layouts Rows, and comments will be empty again.
+//
+// If InputMetadata has been changed since the parse plan is compiled,
+// including through the pointer returned by GetInputMetadataAt, the parse
+// plan is compiled again, so the changes is applied on the next Read.
 //
 func (reader *Reader) Reset() (e error) {
 	e = reader.Flush()
 	if e != nil {
 		return
 	}
+	if reader.plan == nil ||
+		!reflect.DeepEqual(reader.planMetadata, reader.InputMetadata) {
+		e = reader.compilePlan()
+		if e != nil {
+			return
+		}
+	}
+	reader.comments = nil
+	reader.sepCounts = nil
+	for x := range reader.Layouts {
+		ds, ok := reader.Layouts[x].dataset.(tabula.DatasetInterface)
+		if !ok {
+			continue
+		}
+		e = ds.Reset()
+		if e != nil {
+			return
+		}
+	}
 	e = reader.dataset.(tabula.DatasetInterface).Reset()
 	return
 }
@@ -483,29 +967,467 @@ func (reader *Reader) Reset() (e error) {
 //
 // Flush all output buffer.
 //
-func (reader *Reader) Flush() error {
+func (reader *Reader) Flush() (e error) {
 	return reader.bufReject.Flush()
 }
 
+//
+// SaveCheckpoint flush the rejected lines and save the current checkpoint to
+// file, if PersistCheckpoint is true.
+//
+// Read does not save the checkpoint, because the rows has not been processed
+// yet when Read return. SaveCheckpoint should be called after the rows from
+// the last Read has been processed, for example written to output file, so
+// resuming the input from the saved checkpoint will not skip rows that has
+// not been processed.
+//
+func (reader *Reader) SaveCheckpoint() (e error) {
+	e = reader.Flush()
+	if e != nil {
+		return
+	}
+	if reader.PersistCheckpoint {
+		e = reader.checkpoint.Save(reader.GetCheckpointFile())
+	}
+	return
+}
+
+//
+// GetCheckpointFile return the file name where checkpoint will be saved.
+//
+func (reader *Reader) GetCheckpointFile() string {
+	return reader.Input + DefCheckpointExt
+}
+
+//
+// GetCheckpoint return pointer to the current checkpoint of reader.
+// The checkpoint is updated by ReadLine, Reject, and Read, so one should copy
+// the value before saving it.
+//
+func (reader *Reader) GetCheckpoint() *Checkpoint {
+	return &reader.checkpoint
+}
+
+//
+// ResumeFrom set the input position and counters of reader to checkpoint
+// `cp`. The next Read will continue reading the input from the checkpoint
+// offset.
+//
+func (reader *Reader) ResumeFrom(cp Checkpoint) (e error) {
+	e = reader.SeekInput(cp.Offset)
+	if e != nil {
+		return
+	}
+
+	reader.checkpoint = cp
+
+	return nil
+}
+
+//
+// GetIndexFile return the file name where index will be saved.
+//
+func (reader *Reader) GetIndexFile() string {
+	return reader.Input + DefIndexExt
+}
+
+//
+// SetIndex set the index that will be used by SeekRow.
+//
+func (reader *Reader) SetIndex(idx *Index) {
+	reader.index = idx
+}
+
+//
+// BuildIndex read the input from the beginning, after the skipped lines,
+// until the end of file and record the position of every `step`-th record.
+// Each record is parsed using input metadata, so the record with multiple
+// lines is counted as one record.
+// The index is saved to index file, and after the index is build, the input
+// position and checkpoint are restored as before this function is called.
+//
+func (reader *Reader) BuildIndex(step int) (idx *Index, e error) {
+	if step <= 0 {
+		step = DefIndexStep
+	}
+
+	fi, e := os.Stat(reader.Input)
+	if e != nil {
+		return nil, e
+	}
+
+	start := reader.checkpoint
+	idx = &Index{
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UnixNano(),
+		Step:    step,
+	}
+
+	e = reader.ResumeFrom(Checkpoint{})
+	if e != nil {
+		return nil, e
+	}
+
+	e = reader.SkipLines()
+	if e != nil {
+		return nil, e
+	}
+
+	for n := 0; ; n++ {
+		cp := reader.checkpoint
+
+		_, _, _, eRead := ReadRow(reader, 0)
+		if eRead != nil && (eRead.T == EReadEOF ||
+			eRead.T == EReadIdle || eRead.T == EReadTrailer) {
+			break
+		}
+		if eRead != nil && eRead.T == EReadLine {
+			_ = reader.ResumeFrom(start)
+			return nil, eRead
+		}
+
+		if n%step == 0 {
+			idx.Entries = append(idx.Entries, IndexEntry{
+				Offset: cp.Offset,
+				Line:   cp.Line,
+			})
+		}
+	}
+
+	e = reader.ResumeFrom(start)
+	if e != nil {
+		return nil, e
+	}
+
+	e = idx.Save(reader.GetIndexFile())
+	if e != nil {
+		return nil, e
+	}
+
+	reader.index = idx
+
+	return idx, nil
+}
+
+//
+// SeekRow set the input position to the n-th record, start from 0, so the
+// next Read will start from that record.
+//
+// If reader does not have index, it will load the index from index file.
+// If index file is not exist, or the input file has been changed since the
+// index is build, the index will be build using the default step and saved
+// to index file.
+//
+func (reader *Reader) SeekRow(n int) (e error) {
+	if n < 0 {
+		return ErrInvalidOffset
+	}
+	if reader.index == nil {
+		reader.index, e = LoadIndex(reader.GetIndexFile())
+		if e != nil {
+			return
+		}
+		if reader.index != nil && !reader.index.IsValid(reader.Input) {
+			reader.index = nil
+		}
+	}
+	if reader.index == nil {
+		_, e = reader.BuildIndex(DefIndexStep)
+		if e != nil {
+			return
+		}
+	}
+
+	entry, rest, e := reader.index.Find(n)
+	if e != nil {
+		return
+	}
+	if entry == nil {
+		return io.EOF
+	}
+
+	e = reader.SeekInput(entry.Offset)
+	if e != nil {
+		return
+	}
+
+	reader.checkpoint.Offset = entry.Offset
+	reader.checkpoint.Line = entry.Line
+
+	for ; rest > 0; rest-- {
+		_, _, _, eRead := ReadRow(reader, 0)
+		if eRead == nil {
+			continue
+		}
+		if eRead.T == EReadEOF || eRead.T == EReadIdle {
+			return io.EOF
+		}
+		if eRead.T == EReadLine {
+			return eRead
+		}
+	}
+
+	return nil
+}
+
+//
+// loadCheckpoint read the checkpoint from checkpoint file, if its exist.
+//
+func (reader *Reader) loadCheckpoint() (e error) {
+	cp, e := LoadCheckpoint(reader.GetCheckpointFile())
+	if e != nil || cp == nil {
+		return
+	}
+
+	reader.checkpoint = *cp
+
+	return nil
+}
+
+//
+// SeekInput set the position of the next read to byte offset `off` from the
+// beginning of input file.
+//
+// If input is mapped into memory, seeking only change the read position,
+// otherwise the input file will be seeked and the read buffer will be
+// discarded.
+//
+func (reader *Reader) SeekInput(off int64) (e error) {
+	reader.footer = nil
+	reader.recordRest = nil
+	reader.pending = nil
+	if reader.Trailer != nil {
+		reader.Trailer.reset()
+	}
+
+	if reader.mmap != nil {
+		if off < 0 || off > int64(len(reader.mmap)) {
+			return ErrInvalidOffset
+		}
+		reader.mmapOff = int(off)
+		return nil
+	}
+
+	_, e = reader.fRead.Seek(off, io.SeekStart)
+	if e != nil {
+		return e
+	}
+
+	reader.bufRead.Reset(reader.fRead)
+	reader.partial = nil
+	reader.lineSize = 0
+	reader.isTooLong = false
+
+	return nil
+}
+
+//
+// readLineMMap read one line from mapped input file, without EOL.
+// The returned line is a slice of mapped memory with capacity limited to
+// its length, so appending to it will create a new slice instead of writing
+// to the read only memory.
+//
+func (reader *Reader) readLineMMap() (line []byte, e error) {
+	start := reader.mmapOff
+	end := len(reader.mmap)
+
+	if start >= end {
+		return nil, io.EOF
+	}
+
+	x := bytes.IndexByte(reader.mmap[start:], DefEOL)
+	if x < 0 {
+		reader.mmapOff = end
+		e = io.EOF
+	} else {
+		end = start + x
+		reader.mmapOff = end + 1
+	}
+
+	if e == nil {
+		reader.checkpoint.Offset += int64(end - start + 1)
+		reader.checkpoint.Line++
+	}
+
+	if reader.MaxLineBytes > 0 && end-start > reader.MaxLineBytes {
+		end = start + reader.MaxLineBytes
+		if e == nil {
+			e = ErrLineTooLong
+		}
+	}
+
+	return reader.mmap[start:end:end], e
+}
+
+//
+// readLineBuffered read one line from buffered input, without EOL.
+//
+// Only the first MaxLineBytes of line is kept in memory, the rest of line is
+// discarded and ErrLineTooLong is returned along with the truncated line.
+//
+// If end of file is reached before EOL, it will return nil line with the
+// error, and the incomplete line is kept until the next call.
+//
+func (reader *Reader) readLineBuffered() (line []byte, e error) {
+	var chunk []byte
+	max := reader.MaxLineBytes
+
+	for {
+		chunk, e = reader.bufRead.ReadSlice(DefEOL)
+		reader.lineSize += len(chunk)
+
+		if e == nil {
+			// remove EOL
+			chunk = chunk[:len(chunk)-1]
+		}
+
+		if !reader.isTooLong {
+			reader.partial = append(reader.partial, chunk...)
+
+			if max > 0 && len(reader.partial) > max {
+				reader.partial = reader.partial[:max]
+				reader.isTooLong = true
+			}
+		}
+
+		if e != bufio.ErrBufferFull {
+			break
+		}
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	line = reader.partial
+	if reader.isTooLong {
+		e = ErrLineTooLong
+	}
+
+	reader.checkpoint.Offset += int64(reader.lineSize)
+	reader.checkpoint.Line++
+
+	reader.partial = nil
+	reader.lineSize = 0
+	reader.isTooLong = false
+
+	return line, e
+}
+
 //
 // ReadLine will read one line from input file.
 //
+// If Follow is true and no complete line is available, ReadLine will
+// return ErrIdle once, and then wait for the next line on the next call.
+//
+// If reader has a trailer, the trailer line is parsed and skipped, and when
+// reaching the end of file, it will return the ReaderError if trailer is
+// missing or not match with the records that has been read.
+//
 func (reader *Reader) ReadLine() (line []byte, e error) {
-	line, e = reader.bufRead.ReadBytes(DefEOL)
+	for {
+		line, e = reader.readLine(true)
 
-	if e == nil {
-		// remove EOL
-		line = line[:len(line)-1]
+		reader.recordLines = 1
+		reader.recordNext = reader.checkpoint
+		reader.recordRest = reader.recordRest[:0]
+
+		if reader.Trailer == nil || (e != nil && e != io.EOF) {
+			return line, e
+		}
+
+		if len(line) > 0 && reader.Trailer.isMatch(line) {
+			eRead := reader.Trailer.parse(reader, line)
+			if eRead != nil {
+				return line, eRead
+			}
+			if e == nil {
+				continue
+			}
+			line = nil
+		}
+
+		if e == io.EOF {
+			e = reader.Trailer.verify(&reader.checkpoint)
+		}
+
+		return line, e
 	}
+}
 
-	return
+//
+// readLine read one line from input file. If `notifyIdle` is false, in
+// Follow mode, it will wait for the next line without returning ErrIdle.
+//
+func (reader *Reader) readLine(notifyIdle bool) (line []byte, e error) {
+	if len(reader.pending) > 0 {
+		f := reader.pending[0]
+		reader.pending = reader.pending[1:]
+
+		reader.checkpoint.Offset += f.size
+		reader.checkpoint.Line += f.nline
+
+		return f.line, f.e
+	}
+	if reader.SkipFooter > 0 && !reader.Follow {
+		return reader.readLineFooter(notifyIdle)
+	}
+	return reader.readLineInput(notifyIdle)
+}
+
+//
+// readLineInput read one line from mapped memory, followed input file, or
+// buffered input.
+//
+func (reader *Reader) readLineInput(notifyIdle bool) (line []byte, e error) {
+	if reader.mmap != nil {
+		return reader.readLineMMap()
+	}
+	if reader.Follow {
+		return reader.readLineFollow(notifyIdle)
+	}
+
+	line, e = reader.readLineBuffered()
+	if e != nil && e != ErrLineTooLong {
+		// Return the incomplete last line.
+		line = reader.partial
+		reader.partial = nil
+		reader.lineSize = 0
+		reader.isTooLong = false
+	}
+
+	return line, e
 }
 
 //
 // FetchNextLine read the next line and combine it with the `lastline`.
 //
+// If the number of lines in current record is more than MaxRecordLines, or
+// the next line is longer than MaxLineBytes, it will return error and the
+// input position will be set back to the second line of record.
+//
 func (reader *Reader) FetchNextLine(lastline []byte) (line []byte, e error) {
-	line, e = reader.ReadLine()
+	if reader.MaxRecordLines > 0 &&
+		reader.recordLines >= reader.MaxRecordLines {
+		reader.rewindRecord()
+		return lastline, ErrRecordTooLong
+	}
+
+	cp := reader.checkpoint
+
+	line, e = reader.readLine(false)
+	reader.recordLines++
+
+	reader.recordRest = append(reader.recordRest, footerLine{
+		line:  append([]byte(nil), line...),
+		e:     e,
+		size:  reader.checkpoint.Offset - cp.Offset,
+		nline: reader.checkpoint.Line - cp.Line,
+	})
+
+	if e == ErrLineTooLong {
+		reader.rewindRecord()
+		return lastline, e
+	}
 
 	lastline = append(lastline, DefEOL)
 	lastline = append(lastline, line...)
@@ -513,10 +1435,32 @@ func (reader *Reader) FetchNextLine(lastline []byte) (line []byte, e error) {
 	return lastline, e
 }
 
+//
+// rewindRecord set the input position back to the second line of current
+// record, by reading the lines after the first line of record again on the
+// next read.
+// The input file, footer lines, and trailer are not changed.
+//
+func (reader *Reader) rewindRecord() {
+	if len(reader.recordRest) == 0 {
+		return
+	}
+
+	pending := make([]footerLine, 0,
+		len(reader.recordRest)+len(reader.pending))
+	pending = append(pending, reader.recordRest...)
+	reader.pending = append(pending, reader.pending...)
+	reader.recordRest = reader.recordRest[:0]
+
+	reader.checkpoint.Offset = reader.recordNext.Offset
+	reader.checkpoint.Line = reader.recordNext.Line
+}
+
 //
 // Reject the line and save it to the reject file.
 //
 func (reader *Reader) Reject(line []byte) (int, error) {
+	reader.checkpoint.Rejected++
 	return reader.bufReject.Write(line)
 }
 
@@ -529,7 +1473,7 @@ func (reader *Reader) deleteEmptyRejected() {
 		return
 	}
 
-	if finfo.Size() >= 0 {
+	if finfo.Size() == 0 {
 		_ = os.Remove(reader.Rejected)
 	}
 }
@@ -553,8 +1497,23 @@ func (reader *Reader) Close() (e error) {
 
 	reader.deleteEmptyRejected()
 
+	if nil != reader.mmap {
+		e = munmapFile(reader.mmap)
+		reader.mmap = nil
+		if e != nil {
+			return
+		}
+	}
 	if nil != reader.fRead {
 		e = reader.fRead.Close()
+		if e != nil {
+			return
+		}
+
+		cp := &reader.checkpoint
+		reader.GetLogger().Info("input closed", "path", reader.Input,
+			"lines", cp.Line, "rows", cp.Rows,
+			"filtered", cp.Filtered, "rejected", cp.Rejected)
 	}
 	return
 }
@@ -9,6 +9,9 @@ import (
 	"github.com/shuLhan/dsv"
 	"github.com/shuLhan/tabula"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -261,6 +264,45 @@ func TestReaderOpen(t *testing.T) {
 	}
 }
 
+//
+// TestReaderMMap test reading input file using memory mapped.
+//
+func TestReaderMMap(t *testing.T) {
+	dsvReader := &dsv.Reader{}
+
+	e := dsv.ConfigParse(dsvReader, []byte(jsonSample[4]))
+	if nil != e {
+		t.Fatal(e)
+	}
+
+	dsvReader.MMap = true
+
+	e = dsvReader.Init("", nil)
+	if nil != e {
+		t.Fatal(e)
+	}
+
+	doRead(t, dsvReader, expectation)
+
+	// Seek back to the first row, after header.
+	e = dsvReader.SeekInput(int64(len(`"id","name","value","integer";"real"`) + 1))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(dsvReader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, dsvReader, expectation[0])
+
+	e = dsvReader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
 func TestDatasetMode(t *testing.T) {
 	var e error
 	var config = []string{`{
@@ -598,3 +640,1603 @@ func TestMergeRows(t *testing.T) {
 
 	testWriteOutput(t, reader1, outfile, expfile)
 }
+
+//
+// BenchmarkParseLine measure the throughput of ParseLine on each input line
+// using metadata from configuration files in testdata.
+//
+func BenchmarkParseLine(b *testing.B) {
+	configs := []string{
+		"testdata/config.dsv",
+		"testdata/config_skip.dsv",
+	}
+
+	for _, fcfg := range configs {
+		b.Run(fcfg, func(b *testing.B) {
+			reader, e := dsv.NewReader(fcfg, nil)
+			if e != nil {
+				b.Fatal(e)
+			}
+
+			var lines [][]byte
+			var size int64
+
+			for {
+				line, e := reader.ReadLine()
+				if e != nil {
+					break
+				}
+				lines = append(lines, line)
+				size += int64(len(line)) + 1
+			}
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for x := 0; x < b.N; x++ {
+				for _, line := range lines {
+					_, _ = dsv.ParseLine(reader, line)
+				}
+			}
+
+			b.StopTimer()
+
+			e = reader.Close()
+			if e != nil {
+				b.Fatal(e)
+			}
+		})
+	}
+}
+
+//
+// TestReaderResumeFrom test resuming reader from checkpoint of another reader.
+//
+func TestReaderResumeFrom(t *testing.T) {
+	reader1, e := dsv.NewReader("testdata/config.dsv", nil)
+	if nil != e {
+		t.Fatal(e)
+	}
+
+	for x := 0; x < 2; x++ {
+		_, e = dsv.Read(reader1)
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	cp := *reader1.GetCheckpoint()
+
+	assert(t, dsv.Checkpoint{
+		Offset:  83,
+		Line:    3,
+		Rows:    2,
+		Records: 2,
+	}, cp, true)
+
+	e = reader1.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	reader2, e := dsv.NewReader("testdata/config.dsv", nil)
+	if nil != e {
+		t.Fatal(e)
+	}
+
+	e = reader2.ResumeFrom(cp)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	doRead(t, reader2, expectation[2:])
+
+	e = reader2.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+//
+// TestReaderPersistCheckpoint test saving and loading checkpoint from file.
+//
+func TestReaderPersistCheckpoint(t *testing.T) {
+	config := []byte(`{
+		"Input"			:"testdata/input.dat"
+	,	"Rejected"		:"testdata/rejected.dat"
+	,	"PersistCheckpoint"	:true
+	}`)
+
+	newReader := func() *dsv.Reader {
+		reader := &dsv.Reader{}
+
+		e := dsv.ConfigParse(reader, []byte(jsonSample[4]))
+		if e != nil {
+			t.Fatal(e)
+		}
+		e = dsv.ConfigParse(reader, config)
+		if e != nil {
+			t.Fatal(e)
+		}
+		e = reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return reader
+	}
+
+	reader := newReader()
+	fcp := reader.GetCheckpointFile()
+
+	defer func() {
+		_ = os.Remove(fcp)
+	}()
+
+	for x := 0; x < 2; x++ {
+		_, e := dsv.Read(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	e := reader.SaveCheckpoint()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	exp := *reader.GetCheckpoint()
+
+	// The rows from the last Read is not processed, so the checkpoint
+	// is not saved.
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cp, e := dsv.LoadCheckpoint(fcp)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, &exp, cp, true)
+
+	reader = newReader()
+
+	doRead(t, reader, expectation[2:])
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+//
+// TestReaderPersistCheckpointRejected test resuming reader from checkpoint
+// file, where the lines rejected before checkpoint are kept in rejected file.
+//
+func TestReaderPersistCheckpointRejected(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "1;a\nx;b\n2;c\n3;d\ny;e\n4;f\n")
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	newReader := func(maxRows int) *dsv.Reader {
+		reader := &dsv.Reader{
+			Input:             input,
+			Rejected:          rejected,
+			MaxRows:           maxRows,
+			PersistCheckpoint: true,
+			InputMetadata: []dsv.Metadata{{
+				Name:      "id",
+				Type:      "integer",
+				Separator: ";",
+			}, {
+				Name: "name",
+			}},
+		}
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return reader
+	}
+
+	reader := newReader(2)
+
+	_, e := dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[1 a]&[2 c]")
+
+	e = reader.SaveCheckpoint()
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	reader = newReader(-1)
+
+	_, e = dsv.Read(reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[3 d]&[4 f]")
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	got, e := ioutil.ReadFile(rejected)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "x;b\ny;e\n", string(got), true)
+}
+
+//
+// TestReaderSeekRow test seeking to n-th record using index.
+//
+func TestReaderSeekRow(t *testing.T) {
+	content, e := ioutil.ReadFile("testdata/input.dat")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, input := writeTempFile(t, "input.dat", string(content))
+
+	reader, e := dsv.NewReader("testdata/config.dsv", nil)
+	if nil != e {
+		t.Fatal(e)
+	}
+
+	reader.Input = input
+
+	e = reader.Open()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Read the first row, so index is build from the beginning of input
+	// and position is restored after the index is build.
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	idx, e := reader.BuildIndex(2)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, 7, len(idx.Entries), true)
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, expectation[1])
+
+	// Index is saved to file.
+	saved, e := dsv.LoadIndex(reader.GetIndexFile())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, idx, saved, true)
+	assert(t, true, saved.IsValid(input), true)
+
+	e = reader.SeekRow(-1)
+	assert(t, dsv.ErrInvalidOffset, e, true)
+
+	cases := []struct {
+		row int
+		exp string
+	}{{
+		// Record with multiple lines.
+		row: 7,
+		exp: expectation[5],
+	}, {
+		row: 8,
+		exp: expectation[6],
+	}, {
+		row: 0,
+		exp: expectation[0],
+	}}
+
+	for _, c := range cases {
+		e = reader.SeekRow(c.row)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, c.exp)
+	}
+
+	e = reader.SeekRow(14)
+	assert(t, io.EOF, e, true)
+
+	// Index of input that has been changed is build again.
+	saved.Size = 0
+	saved.Entries = nil
+
+	e = saved.Save(reader.GetIndexFile())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	reader.SetIndex(nil)
+
+	e = reader.SeekRow(8)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, expectation[6])
+
+	saved, e = dsv.LoadIndex(reader.GetIndexFile())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, idx.Size, saved.Size, true)
+	assert(t, dsv.DefIndexStep, saved.Step, true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+//
+// TestReaderMaxBytes test rejecting line, record, and field that is longer
+// than the limit.
+//
+func TestReaderMaxBytes(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", `1;[a]
+2;[this line is too long]
+3;[multi
+ok]
+4;[unmatched
+5;[x
+6;[y
+7;[cccccccccc]
+8;[ok]
+`)
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	for _, mmap := range []bool{false, true} {
+		reader := &dsv.Reader{
+			Input:          input,
+			Rejected:       rejected,
+			MaxRows:        -1,
+			MMap:           mmap,
+			MaxLineBytes:   16,
+			MaxRecordLines: 2,
+			MaxFieldBytes:  8,
+			InputMetadata: []dsv.Metadata{{
+				Name:      "id",
+				Type:      "integer",
+				Separator: ";",
+			}, {
+				Name:       "name",
+				LeftQuote:  "[",
+				RightQuote: "]",
+			}},
+		}
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != io.EOF {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, "&[1 a]&[3 multi\nok]&[8 ok]")
+
+		got, e := ioutil.ReadFile(rejected)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		exp := "2;[this line is \n4;[unmatched\n5;[x\n6;[y\n"
+
+		assert(t, exp, string(got), true)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+//
+// TestReaderMaxRecord test rejecting record with too many lines or too long
+// field, and continue reading from the second line of record.
+//
+func TestReaderMaxRecord(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"1;[a]\n2;[b\nc\nd\n3;[e]\n-- end\n")
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	cases := []struct {
+		desc           string
+		maxRecordLines int
+		maxFieldBytes  int
+		expRejected    string
+	}{{
+		desc:           "With MaxRecordLines",
+		maxRecordLines: 2,
+		expRejected:    "2;[b\nc\nd\n",
+	}, {
+		desc:          "With MaxFieldBytes only",
+		maxFieldBytes: 4,
+		expRejected:   "2;[b\nc\nd\n",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		reader := &dsv.Reader{
+			Input:          input,
+			Rejected:       rejected,
+			MaxRows:        -1,
+			SkipFooter:     1,
+			MaxRecordLines: c.maxRecordLines,
+			MaxFieldBytes:  c.maxFieldBytes,
+			InputMetadata: []dsv.Metadata{{
+				Name:      "id",
+				Type:      "integer",
+				Separator: ";",
+			}, {
+				Name:       "name",
+				LeftQuote:  "[",
+				RightQuote: "]",
+			}},
+		}
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != io.EOF {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, "&[1 a]&[3 e]")
+
+		got, e := ioutil.ReadFile(rejected)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, c.expRejected, string(got), true)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+//
+// TestReaderComment test skipping comment and blank lines.
+//
+func TestReaderComment(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"# comment 1\na\n\n  #! comment 2\nb\n")
+
+	cases := []struct {
+		skipBlank bool
+		exp       string
+	}{{
+		skipBlank: true,
+		exp:       "&[a]&[b]",
+	}, {
+		skipBlank: false,
+		exp:       "&[a]&[]&[b]",
+	}}
+
+	for _, c := range cases {
+		reader := &dsv.Reader{
+			Input:        input,
+			Rejected:     filepath.Join(dir, "rejected.dat"),
+			MaxRows:      -1,
+			Comment:      "#",
+			KeepComments: true,
+			InputMetadata: []dsv.Metadata{{
+				Name: "line",
+			}},
+		}
+
+		reader.SetSkipBlankLines(c.skipBlank)
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != io.EOF {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, c.exp)
+
+		assert(t, []string{"# comment 1", "#! comment 2"},
+			reader.GetComments(), true)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+func TestReaderTrailer(t *testing.T) {
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "input.dat")
+
+	// duplicate each row, to check that trailer count the records
+	// before its transformed.
+	dup := dsv.RowTransformerFunc(func(row *tabula.Row,
+		md []dsv.MetadataInterface,
+	) (
+		[]*tabula.Row, error,
+	) {
+		return []*tabula.Row{row, row}, nil
+	})
+
+	cases := []struct {
+		desc       string
+		content    string
+		skipFooter int
+		isDup      bool
+		expOffset  int64
+		expLine    int
+		expErr     string
+	}{{
+		desc:      "With trailer match",
+		content:   "a,1\nb,2\nc,3.5\nTRL|3|6.5",
+		expOffset: 14,
+		expLine:   3,
+	}, {
+		desc:       "With trailer in footer lines",
+		content:    "a,1\nb,2\nc,3.5\nTRL|3|6.5\n-- end\n",
+		skipFooter: 1,
+		expOffset:  31,
+		expLine:    5,
+	}, {
+		desc:       "With trailer as the last footer line",
+		content:    "a,1\nb,2\nc,3.5\n-- end\nTRL|3|6.5\n",
+		skipFooter: 1,
+		expOffset:  31,
+		expLine:    5,
+	}, {
+		desc:       "With trailer before footer lines",
+		content:    "a,1\nb,2\nc,3.5\nTRL|3|6.5\n-- page 1\n-- end\n",
+		skipFooter: 2,
+		expOffset:  41,
+		expLine:    6,
+	}, {
+		desc:      "With transformer",
+		content:   "a,1\nb,2\nc,3.5\nTRL|3|6.5\n",
+		isDup:     true,
+		expOffset: 24,
+		expLine:   4,
+	}, {
+		desc:    "With count mismatch",
+		content: "a,1\nb,2\nc,3.5\nTRL|4|6.5\n",
+		expErr:  "Trailer count 4 does not match with number of records 3",
+	}, {
+		desc:    "With sum mismatch",
+		content: "a,1\nb,2\nc,3.5\nTRL|3|6\n",
+		expErr:  "Trailer sum of value 6 does not match with 6.5",
+	}, {
+		desc:    "With missing trailer",
+		content: "a,1\nb,2\nc,3.5\n",
+		expErr:  "Missing trailer line",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		e := ioutil.WriteFile(input, []byte(c.content), 0600)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		reader := &dsv.Reader{
+			Input:      input,
+			Rejected:   filepath.Join(dir, "rejected.dat"),
+			MaxRows:    -1,
+			SkipFooter: c.skipFooter,
+			InputMetadata: []dsv.Metadata{{
+				Name:      "name",
+				Separator: ",",
+			}, {
+				Name: "value",
+				Type: "real",
+			}},
+			Trailer: &dsv.Trailer{
+				Pattern: `^TRL\|`,
+				Metadata: []dsv.Metadata{{
+					Name:      "tag",
+					Separator: "|",
+					Skip:      true,
+				}, {
+					Name:      "count",
+					Separator: "|",
+				}, {
+					Name: "sum",
+				}},
+				Count:     "count",
+				Sum:       "sum",
+				SumColumn: "value",
+			},
+		}
+
+		if c.isDup {
+			reader.AddTransformer(dup)
+		}
+
+		e = reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		n, e := dsv.Read(reader)
+
+		if c.isDup {
+			assert(t, 6, n, true)
+			checkDataset(t, reader,
+				"&[a 1]&[a 1]&[b 2]&[b 2]&[c 3.5]&[c 3.5]")
+		} else {
+			assert(t, 3, n, true)
+			checkDataset(t, reader, "&[a 1]&[b 2]&[c 3.5]")
+		}
+
+		if c.expErr == "" {
+			assert(t, io.EOF, e, true)
+
+			// The trailer and footer lines should be included in
+			// checkpoint, except the last line without EOL.
+			cp := reader.GetCheckpoint()
+			assert(t, c.expOffset, cp.Offset, true)
+			assert(t, c.expLine, cp.Line, true)
+		} else {
+			eRead, ok := e.(*dsv.ReaderError)
+			if !ok {
+				t.Fatalf("expecting ReaderError, got %v", e)
+			}
+			assert(t, dsv.EReadTrailer, eRead.T, true)
+			assert(t, c.expErr, eRead.What, true)
+
+			// The next Read should return EOF.
+			_, e = dsv.Read(reader)
+			assert(t, io.EOF, e, true)
+		}
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+func TestReaderSkipFooter(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"name\na\nb\nc\n-- total 3\n-- end")
+
+	for _, mmap := range []bool{false, true} {
+		reader := &dsv.Reader{
+			Input:      input,
+			Rejected:   filepath.Join(dir, "rejected.dat"),
+			MaxRows:    2,
+			Skip:       1,
+			SkipFooter: 2,
+			MMap:       mmap,
+			InputMetadata: []dsv.Metadata{{
+				Name: "name",
+			}},
+		}
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, "&[a]&[b]")
+
+		// The checkpoint should not include the lines in
+		// look-ahead.
+		assert(t, dsv.Checkpoint{
+			Offset:  9,
+			Line:    3,
+			Rows:    2,
+			Records: 2,
+		}, *reader.GetCheckpoint(), true)
+
+		_, e = dsv.Read(reader)
+		assert(t, io.EOF, e, true)
+
+		checkDataset(t, reader, "&[c]")
+
+		// The footer lines should be included in checkpoint after
+		// the end of file, except the last line without EOL.
+		assert(t, dsv.Checkpoint{
+			Offset:  22,
+			Line:    5,
+			Rows:    3,
+			Records: 3,
+		}, *reader.GetCheckpoint(), true)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+func TestReaderCopyConfig(t *testing.T) {
+	src := &dsv.Reader{
+		Input: "input.dat",
+		Layouts: []dsv.Layout{{
+			Name:   "header",
+			Prefix: "H",
+			InputMetadata: []dsv.Metadata{{
+				Name: "type",
+			}},
+		}},
+		Trailer: &dsv.Trailer{
+			Pattern: `^TRL\|`,
+			Metadata: []dsv.Metadata{{
+				Name:      "tag",
+				Separator: "|",
+			}, {
+				Name: "count",
+			}},
+			Count: "count",
+		},
+	}
+
+	reader := &dsv.Reader{}
+	reader.CopyConfig(src)
+
+	assert(t, src.Layouts, reader.Layouts, true)
+	assert(t, src.Trailer, reader.Trailer, true)
+
+	// The copied layouts and trailer does not share metadata with
+	// source.
+	reader.Layouts[0].InputMetadata[0].Name = "kind"
+	reader.Trailer.Metadata[1].Name = "total"
+
+	assert(t, "type", src.Layouts[0].InputMetadata[0].Name, true)
+	assert(t, "count", src.Trailer.Metadata[1].Name, true)
+}
+
+func TestReaderLayouts(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"H|20181018\nD|a|1\nD|b|2\nX|unknown\nS|2\n")
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	mdType := dsv.Metadata{
+		Name:      "type",
+		Separator: "|",
+		Skip:      true,
+	}
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: rejected,
+		MaxRows:  -1,
+		Layouts: []dsv.Layout{{
+			Name:   "header",
+			Prefix: "H|",
+			InputMetadata: []dsv.Metadata{mdType, {
+				Name: "date",
+			}},
+		}, {
+			Name:  "detail",
+			Value: "D",
+			InputMetadata: []dsv.Metadata{mdType, {
+				Name:      "name",
+				Separator: "|",
+			}, {
+				Name: "value",
+				Type: "integer",
+			}},
+		}, {
+			Name:   "summary",
+			Prefix: "S|",
+			InputMetadata: []dsv.Metadata{mdType, {
+				Name: "count",
+				Type: "integer",
+			}},
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	n, e := dsv.Read(reader)
+
+	assert(t, io.EOF, e, true)
+	assert(t, 4, n, true)
+
+	exp := map[string]string{
+		"header":  "&[20181018]",
+		"detail":  "&[a 1]&[b 2]",
+		"summary": "&[2]",
+	}
+
+	for name, rows := range exp {
+		layout := reader.GetLayout(name)
+		ds := layout.GetDataset().(tabula.DatasetInterface)
+		assert(t, rows, fmt.Sprint(*ds.GetDataAsRows()), true)
+	}
+
+	checkDataset(t, reader, "")
+
+	// Line that does not match with any layout is rejected.
+	got, e := ioutil.ReadFile(rejected)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "X|unknown\n", string(got), true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReaderPattern(t *testing.T) {
+	lines := `127.0.0.1 - - [18/Oct/2018:10:00:00 +0700] "GET / HTTP/1.1" 200 512
+invalid line
+10.0.0.2 - bob [18/Oct/2018:10:00:01 +0700] "POST /login HTTP/1.1" 302 -
+`
+
+	dir, input := writeTempFile(t, "input.log", lines)
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: rejected,
+		MaxRows:  -1,
+		Pattern: `^(?P<host>\S+) \S+ (?P<user>\S+) \[(?P<time>[^\]]+)\] ` +
+			`"(?P<request>[^"]*)" (?P<status>\d+) (?P<size>\S+)$`,
+		InputMetadata: []dsv.Metadata{{
+			Name: "host",
+		}, {
+			Name: "user",
+			Skip: true,
+		}, {
+			Name: "request",
+		}, {
+			Name: "status",
+			Type: "integer",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	n, e := dsv.Read(reader)
+
+	assert(t, io.EOF, e, true)
+	assert(t, 2, n, true)
+
+	checkDataset(t, reader, "&[127.0.0.1 GET / HTTP/1.1 200]"+
+		"&[10.0.0.2 POST /login HTTP/1.1 302]")
+
+	got, e := ioutil.ReadFile(rejected)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "invalid line\n", string(got), true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Metadata without named group in pattern should return error.
+	reader.Pattern = `^(?P<host>\S+)`
+
+	e = reader.Init("", nil)
+	if e == nil {
+		t.Fatal("expecting error on missing group")
+	}
+
+	// Metadata that is changed into name without named group in pattern
+	// should return error on the next Read.
+	reader = &dsv.Reader{
+		Input:    input,
+		Rejected: rejected,
+		MaxRows:  -1,
+		Pattern:  `^(?P<host>\S+) `,
+		InputMetadata: []dsv.Metadata{{
+			Name: "host",
+		}},
+	}
+
+	e = reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	reader.GetInputMetadataAt(0).(*dsv.Metadata).Name = "addr"
+
+	_, e = dsv.Read(reader)
+	if e == nil {
+		t.Fatal("expecting error on missing group")
+	}
+
+	assert(t, `dsv: Pattern does not have group "addr"`, e.Error(), true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReaderPreset(t *testing.T) {
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "input.log")
+
+	cases := []struct {
+		preset string
+		line   string
+		exp    string
+	}{{
+		preset: dsv.PresetApacheCombined,
+		line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] ` +
+			`"GET /apache_pb.gif HTTP/1.0" 304 - ` +
+			`"http://www.example.com/start.html" "Mozilla/4.08"`,
+		exp: "&[127.0.0.1 - frank 10/Oct/2000:13:55:36 -0700 GET " +
+			"/apache_pb.gif HTTP/1.0 304  " +
+			"http://www.example.com/start.html Mozilla/4.08]",
+	}, {
+		preset: dsv.PresetApacheCombined,
+		line: `10.0.0.2 - - [10/Oct/2000:13:55:36 -0700] "-" 408 152 ` +
+			`"-" "-"`,
+		exp: "&[10.0.0.2 - - 10/Oct/2000:13:55:36 -0700    408 152 - -]",
+	}, {
+		preset: dsv.PresetNginx,
+		line: `10.0.0.1 - - [18/Oct/2018:10:00:00 +0700] ` +
+			`"GET / HTTP/1.1" 200 612 "-" "curl/7.61.1"`,
+		exp: "&[10.0.0.1 - 18/Oct/2018:10:00:00 +0700 GET / HTTP/1.1 " +
+			"200 612 - curl/7.61.1]",
+	}, {
+		preset: dsv.PresetSyslogRFC3164,
+		line:   `Oct 18 10:00:00 myhost sshd[1234]: Accepted publickey`,
+		exp:    "&[ Oct 18 10:00:00 myhost sshd 1234 Accepted publickey]",
+	}, {
+		preset: dsv.PresetSyslogRFC3164,
+		line:   `<34>Oct  8 10:00:00 myhost su: 'su root' failed`,
+		exp:    "&[34 Oct  8 10:00:00 myhost su  'su root' failed]",
+	}, {
+		preset: dsv.PresetSyslogRFC5424,
+		line: `<165>1 2003-10-11T22:14:15.003Z mymachine evntslog - ` +
+			`ID47 [exampleSDID@32473 iut="3"] An application event`,
+		exp: "&[165 1 2003-10-11T22:14:15.003Z mymachine evntslog - " +
+			"ID47 [exampleSDID@32473 iut=\"3\"] An application event]",
+	}, {
+		preset: dsv.PresetLogfmt,
+		line: `level=info msg="user \"bob\" login" ` +
+			`time=2018-10-18T10:00:00Z debug`,
+		exp: "&[2018-10-18T10:00:00Z info user \"bob\" login]",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.preset)
+
+		e := ioutil.WriteFile(input, []byte(c.line+"\n"), 0600)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		reader := &dsv.Reader{
+			Input:    input,
+			Rejected: filepath.Join(dir, "rejected.dat"),
+			MaxRows:  -1,
+			Preset:   c.preset,
+		}
+
+		e = reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != io.EOF {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, c.exp)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+func TestReaderSeparatorWhitespace(t *testing.T) {
+	lines := "-rw-r--r--  1 root\troot   4096 Oct 18 10:00 my file.txt\n" +
+		"drwxr-xr-x 2 ms \t ms　  60 Oct  8 09:00 dir\n"
+
+	dir, input := writeTempFile(t, "input.dat", lines)
+
+	ws := dsv.SeparatorModeWhitespace
+
+	cases := []struct {
+		desc string
+		last dsv.Metadata
+		exp  string
+	}{{
+		desc: "With last column as remainder",
+		last: dsv.Metadata{
+			Name:      "name",
+			Remainder: true,
+		},
+		exp: "&[-rw-r--r-- 1 root root 4096 Oct 18 10:00 my file.txt]" +
+			"&[drwxr-xr-x 2 ms ms 60 Oct 8 09:00 dir]",
+	}, {
+		desc: "With last column as one field",
+		last: dsv.Metadata{
+			Name:      "name",
+			Separator: dsv.SeparatorWhitespace,
+		},
+		exp: "&[-rw-r--r-- 1 root root 4096 Oct 18 10:00 my]" +
+			"&[drwxr-xr-x 2 ms ms 60 Oct 8 09:00 dir]",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		reader := &dsv.Reader{
+			Input:    input,
+			Rejected: filepath.Join(dir, "rejected.dat"),
+			MaxRows:  -1,
+			InputMetadata: []dsv.Metadata{
+				{Name: "mode", SeparatorMode: ws},
+				{Name: "links", SeparatorMode: ws, Type: "integer"},
+				{Name: "owner", SeparatorMode: ws},
+				{Name: "group", SeparatorMode: ws},
+				{Name: "size", SeparatorMode: ws, Type: "integer"},
+				{Name: "month", SeparatorMode: ws},
+				{Name: "day", SeparatorMode: ws, Type: "integer"},
+				{Name: "time", SeparatorMode: ws},
+				c.last,
+			},
+		}
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != io.EOF {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, c.exp)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+func TestReaderSeparators(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "a;1\nb,,2\nc,x\n")
+
+	cases := []struct {
+		match     string
+		exp       string
+		expCounts map[string]int
+	}{{
+		// The rejected lines is not counted.
+		match: dsv.SeparatorMatchFirst,
+		exp:   "&[a 1]",
+		expCounts: map[string]int{
+			";":  1,
+			",":  0,
+			",,": 0,
+		},
+	}, {
+		match: dsv.SeparatorMatchLongest,
+		exp:   "&[a 1]&[b 2]",
+		expCounts: map[string]int{
+			";":  1,
+			",":  0,
+			",,": 1,
+		},
+	}}
+
+	for _, c := range cases {
+		t.Log(c.match)
+
+		reader := &dsv.Reader{
+			Input:    input,
+			Rejected: filepath.Join(dir, "rejected.dat"),
+			MaxRows:  -1,
+			InputMetadata: []dsv.Metadata{{
+				Name:           "name",
+				Separator:      ";",
+				Separators:     []string{",", ",,"},
+				SeparatorMatch: c.match,
+			}, {
+				Name: "value",
+				Type: "real",
+			}},
+		}
+
+		e := reader.Init("", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = dsv.Read(reader)
+		if e != io.EOF {
+			t.Fatal(e)
+		}
+
+		checkDataset(t, reader, c.exp)
+
+		assert(t, c.expCounts, reader.GetSeparatorCount("name"), true)
+
+		// The counts is reset on the next Read.
+		_, e = dsv.Read(reader)
+		if e != io.EOF {
+			t.Fatal(e)
+		}
+
+		assert(t, map[string]int{";": 0, ",": 0, ",,": 0},
+			reader.GetSeparatorCount("name"), true)
+
+		e = reader.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+}
+
+//
+// TestReaderChangeMetadata test that changes on input metadata after Init is
+// applied on the next Read.
+//
+func TestReaderChangeMetadata(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "a;1|x\nb;2|y\n")
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		MaxRows:  1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "name",
+			Separator: ";",
+		}, {
+			Name: "value",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[a 1|x]")
+
+	md := reader.GetInputMetadataAt(0).(*dsv.Metadata)
+	md.Separator = "|"
+
+	_, e = dsv.Read(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[b;2 y]")
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReaderTransform(t *testing.T) {
+	line := "  a , Ｆｕｌｌ  width , hello \t WORLD , e\u0301 \n"
+
+	dir, input := writeTempFile(t, "input.dat", line)
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		MaxRows:  -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "none",
+			Separator: ",",
+		}, {
+			Name:          "nfkc",
+			Separator:     ",",
+			Trim:          dsv.TrimBoth,
+			Normalize:     dsv.NormNFKC,
+			CollapseSpace: true,
+		}, {
+			Name:          "title",
+			Separator:     ",",
+			Trim:          dsv.TrimLeft,
+			Case:          dsv.CaseTitle,
+			CollapseSpace: true,
+		}, {
+			Name:      "nfc",
+			Trim:      dsv.TrimBoth,
+			Normalize: dsv.NormNFC,
+			Case:      dsv.CaseUpper,
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[  a  Full width Hello World  É]")
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReaderFilter(t *testing.T) {
+	lines := "1,ERROR,700\n2,INFO,900\n3,ERROR,100\nx,ERROR,900\n" +
+		"4,ERROR,501\n"
+
+	dir, input := writeTempFile(t, "input.dat", lines)
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	mds := []dsv.Metadata{{
+		Name:      "id",
+		Type:      "integer",
+		Separator: ",",
+	}, {
+		Name:      "status",
+		Separator: ",",
+	}, {
+		Name: "latency",
+		Type: "integer",
+	}}
+
+	reader := &dsv.Reader{
+		Input:         input,
+		Rejected:      rejected,
+		MaxRows:       -1,
+		InputMetadata: mds,
+		Filter:        `status == "ERROR" && latency > 500`,
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	n, e := dsv.Read(reader)
+
+	assert(t, io.EOF, e, true)
+	assert(t, 2, n, true)
+
+	checkDataset(t, reader, "&[1 ERROR 700]&[4 ERROR 501]")
+
+	cp := reader.GetCheckpoint()
+
+	assert(t, 2, cp.Rows, true)
+	assert(t, 2, cp.Filtered, true)
+	assert(t, 1, cp.Rejected, true)
+
+	got, e := ioutil.ReadFile(rejected)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "x,ERROR,900\n", string(got), true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Filter with unknown column.
+	reader = &dsv.Reader{
+		Input:         input,
+		Rejected:      rejected,
+		MaxRows:       -1,
+		InputMetadata: mds,
+		Filter:        `level == "ERROR"`,
+	}
+
+	e = reader.Init("", nil)
+
+	exp := `dsv: Expression "level == \"ERROR\"": unknown column "level"`
+	if e == nil {
+		t.Fatal("expecting error: " + exp)
+	}
+
+	assert(t, exp, e.Error(), true)
+}
+
+func TestReaderTransformers(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"1,x,a|b\n2,x,skip\n3,x,bad\n4,x,c\n")
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	// split the name by "|" into multiple rows.
+	split := dsv.RowTransformerFunc(func(row *tabula.Row,
+		md []dsv.MetadataInterface,
+	) (
+		rows []*tabula.Row, e error,
+	) {
+		for _, name := range strings.Split((*row)[1].String(), "|") {
+			rows = append(rows, &tabula.Row{
+				(*row)[0],
+				tabula.NewRecordString(name),
+			})
+		}
+		return rows, nil
+	})
+
+	// drop or reject the row by name, and convert the name to upper
+	// case.
+	check := dsv.RowTransformerFunc(func(row *tabula.Row,
+		md []dsv.MetadataInterface,
+	) (
+		[]*tabula.Row, error,
+	) {
+		// The metadata of skipped column is not included.
+		if len(md) != row.Len() || md[1].GetName() != "name" {
+			return nil, fmt.Errorf("invalid metadata %v", md)
+		}
+
+		switch name := (*row)[1].String(); name {
+		case "skip":
+			return nil, nil
+		case "bad":
+			return nil, fmt.Errorf("invalid name %q", name)
+		}
+
+		(*row)[1] = tabula.NewRecordString(strings.ToUpper(
+			(*row)[1].String()))
+
+		return []*tabula.Row{row}, nil
+	})
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: rejected,
+		MaxRows:  -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name:      "note",
+			Separator: ",",
+			Skip:      true,
+		}, {
+			Name: "name",
+		}},
+		Transformers: dsv.RowTransformers{split},
+	}
+
+	reader.AddTransformer(check)
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	n, e := dsv.Read(reader)
+
+	assert(t, io.EOF, e, true)
+	assert(t, 3, n, true)
+
+	checkDataset(t, reader, "&[1 A]&[1 B]&[4 C]")
+
+	cp := reader.GetCheckpoint()
+
+	assert(t, 1, cp.Filtered, true)
+	assert(t, 1, cp.Rejected, true)
+	assert(t, 4, cp.Records, true)
+
+	got, e := ioutil.ReadFile(rejected)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert(t, "3,x,bad\n", string(got), true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestReaderProgress(t *testing.T) {
+	_, input := writeTempFile(t, "input.dat",
+		"1,a\n2,b\n3,c\n4,d\n5,e\n")
+
+	var got []int
+
+	reader := &dsv.Reader{
+		Input:        input,
+		MaxRows:      -1,
+		ProgressRows: 2,
+		Progress: func(stats dsv.Stats) {
+			got = append(got, stats.RowsRead)
+		},
+		InputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name: "name",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	n, e := dsv.Read(reader)
+
+	assert(t, io.EOF, e, true)
+	assert(t, 5, n, true)
+	assert(t, []int{2, 4}, got, true)
+
+	stats := reader.Stats()
+
+	assert(t, int64(20), stats.Bytes, true)
+	assert(t, 5, stats.Lines, true)
+	assert(t, 5, stats.RowsRead, true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+//
+// testLogger is the Logger that save each event as one line, in the format
+// "LEVEL msg key=value ...".
+//
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) log(level, msg string, args []interface{}) {
+	line := level + " " + msg
+	for x := 0; x+1 < len(args); x += 2 {
+		line += fmt.Sprintf(" %v=%v", args[x], args[x+1])
+	}
+	l.lines = append(l.lines, line)
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.log("DEBUG", msg, args)
+}
+
+func (l *testLogger) Info(msg string, args ...interface{}) {
+	l.log("INFO", msg, args)
+}
+
+func (l *testLogger) Warn(msg string, args ...interface{}) {
+	l.log("WARN", msg, args)
+}
+
+func (l *testLogger) Error(msg string, args ...interface{}) {
+	l.log("ERROR", msg, args)
+}
+
+func TestReaderLogger(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "1,a\nx,b\n3,c\n")
+	rejected := filepath.Join(dir, "rejected.dat")
+
+	logger := &testLogger{}
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: rejected,
+		MaxRows:  -1,
+		Logger:   logger,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name: "name",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	n, e := dsv.Read(reader)
+
+	assert(t, io.EOF, e, true)
+	assert(t, 2, n, true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	exp := []string{
+		`DEBUG rejected opened path=` + rejected,
+		`INFO input opened path=` + input + ` offset=0 mmap=false`,
+		`WARN line rejected line=2 pos=2 func=ParseLine ` +
+			`error=md id: Type convertion error from "x" to integer ` +
+			`data=x,b`,
+		`INFO input closed path=` + input +
+			` lines=3 rows=2 filtered=0 rejected=1`,
+	}
+
+	assert(t, exp, logger.lines, true)
+}
@@ -23,6 +23,30 @@ const (
 	// ETypeConversion error when converting type from string to numeric or
 	// vice versa.
 	ETypeConversion
+	// EReadIdle error which indicated no new line in input, when reader
+	// is in Follow mode.
+	EReadIdle
+	// EReadLineTooLong error when the length of line is more than
+	// MaxLineBytes.
+	EReadLineTooLong
+	// EReadRecordTooLong error when the number of lines in one record is
+	// more than MaxRecordLines.
+	EReadRecordTooLong
+	// EReadFieldTooLong error when the length of field value is more than
+	// MaxFieldBytes.
+	EReadFieldTooLong
+	// EReadTrailer error when the trailer line is missing or its values
+	// does not match with the records that has been read.
+	EReadTrailer
+	// EReadLayout error when line does not match with any layout.
+	EReadLayout
+	// EReadPattern error when line does not match with reader Pattern.
+	EReadPattern
+	// EReadFilter error when reader Filter can not be evaluated on row.
+	EReadFilter
+	// EReadTransform error when one of reader transformers return an
+	// error.
+	EReadTransform
 )
 
 //
@@ -10,7 +10,8 @@ import (
 	"github.com/shuLhan/tabula"
 	"github.com/shuLhan/tekstus"
 	"io"
-	"os"
+	"unicode"
+	"unicode/utf8"
 )
 
 //
@@ -50,12 +51,88 @@ type ReaderInterface interface {
 	MergeColumns(ReaderInterface)
 }
 
+//
+// CommentReaderInterface is the optional interface for reader that skip
+// comment lines and, optionally, blank lines.
+// Reader that does not implement it skip only blank lines.
+//
+type CommentReaderInterface interface {
+	IsSkipBlankLines() bool
+	GetComment() string
+	PushComment(line []byte)
+}
+
+//
+// CheckpointReaderInterface is the optional interface for reader that keep
+// the position and counters of rows that has been read.
+//
+type CheckpointReaderInterface interface {
+	GetCheckpoint() *Checkpoint
+}
+
+//
+// TrailerReaderInterface is the optional interface for reader that verify
+// the rows that has been read with trailer line.
+//
+type TrailerReaderInterface interface {
+	GetTrailer() *Trailer
+}
+
+//
+// LayoutReaderInterface is the optional interface for reader that parse the
+// line using different record layouts.
+//
+type LayoutReaderInterface interface {
+	SelectLayout(line []byte) (*Layout, error)
+}
+
+//
+// FilterReaderInterface is the optional interface for reader that read only
+// the rows that match with filter.
+//
+type FilterReaderInterface interface {
+	MatchFilter(row *tabula.Row) (bool, error)
+}
+
+//
+// TransformerReaderInterface is the optional interface for reader that
+// transform each row that has been read.
+//
+type TransformerReaderInterface interface {
+	ApplyTransformers(row *tabula.Row) ([]*tabula.Row, error)
+}
+
+//
+// ProgressReaderInterface is the optional interface for reader that report
+// the progress of reading.
+//
+type ProgressReaderInterface interface {
+	ReportProgress(isEnd bool)
+}
+
 //
 // Read row from input file.
 //
+// On return, the reader checkpoint contain the position of the next line in
+// input file and the number of rows and rejected lines that has been read
+// so far.
+//
+// If reader has a trailer and its not match with the rows that has been read,
+// it will return the ReaderError with type EReadTrailer, instead of io.EOF.
+//
+// Row that does not match with reader filter, or dropped by reader
+// transformers, is not saved in dataset and not rejected, but counted in
+// checkpoint Filtered. Row that failed to be transformed is rejected.
+// Row that is parsed using layout is not filtered nor transformed.
+//
+// The reader progress is reported after each row, and the reader statistics
+// is updated before Read return.
+//
 func Read(reader ReaderInterface) (n int, e error) {
 	var (
 		row     *tabula.Row
+		rows    []*tabula.Row
+		layout  *Layout
 		line    []byte
 		linenum int
 		eRead   *ReaderError
@@ -68,29 +145,84 @@ func Read(reader ReaderInterface) (n int, e error) {
 	}
 
 	dataset := reader.GetDataset().(tabula.DatasetInterface)
+	cp := getCheckpoint(reader)
+	counter, isCounter := reader.(separatorCounter)
+	progress, isProgress := reader.(ProgressReaderInterface)
+
+	if isProgress {
+		defer progress.ReportProgress(true)
+	}
 
 	// Loop until we reached MaxRows (> 0) or when all rows has been
 	// read (= -1)
 	for {
-		row, line, linenum, eRead = ReadRow(reader, linenum)
+		if isProgress {
+			progress.ReportProgress(false)
+		}
+
+		row, layout, line, linenum, eRead = readRow(reader, linenum)
+		if nil == eRead || isRecordError(eRead) {
+			cp.Records++
+		}
+		if nil == eRead && layout == nil && isCounter {
+			counter.countSeparators(getParsePlan(reader))
+		}
 		if nil == eRead {
-			dataset.PushRow(row)
+			if layout != nil {
+				rows = []*tabula.Row{row}
+			} else {
+				rows, eRead = acceptRow(reader, cp, row, line)
+			}
+		}
+		if nil == eRead {
+			if len(rows) == 0 {
+				cp.Filtered++
+				continue
+			}
 
-			n++
+			for _, row = range rows {
+				if layout != nil {
+					layout.dataset.(tabula.DatasetInterface).PushRow(row)
+				} else {
+					dataset.PushRow(row)
+				}
+			}
+			cp.Rows += len(rows)
+
+			n += len(rows)
 			if maxrows > 0 && n >= maxrows {
 				break
 			}
 			continue
 		}
 
-		if eRead.T&EReadEOF == EReadEOF {
+		// In Follow mode, return the rows that has been read
+		// before waiting for new line.
+		if eRead.T == EReadIdle {
+			if n > 0 {
+				break
+			}
+			continue
+		}
+
+		if eRead.T == EReadEOF {
 			_ = reader.Flush()
 			e = io.EOF
 			return
 		}
 
+		// The trailer line does not match with records that has been
+		// read.
+		if eRead.T == EReadTrailer {
+			_ = reader.Flush()
+			e = eRead
+			return
+		}
+
 		eRead.N = linenum
-		fmt.Fprintf(os.Stderr, "%s\n", eRead)
+		getLogger(reader).Warn("line rejected", "line", eRead.N,
+			"pos", eRead.Pos, "func", eRead.Func,
+			"error", eRead.What, "data", eRead.Line)
 
 		// If error, save the rejected line.
 		line = append(line, DefEOL)
@@ -107,6 +239,60 @@ func Read(reader ReaderInterface) (n int, e error) {
 	return n, e
 }
 
+//
+// acceptRow apply the reader filter and transformers to the row that is
+// parsed using reader InputMetadata, and add the row value to the trailer sum.
+// It will return empty rows if row does not match with filter or dropped by
+// transformers.
+//
+func acceptRow(reader ReaderInterface, cp *Checkpoint, row *tabula.Row,
+	line []byte,
+) (
+	rows []*tabula.Row, eRead *ReaderError,
+) {
+	var e error
+
+	isMatch := true
+	if filter, ok := reader.(FilterReaderInterface); ok {
+		isMatch, e = filter.MatchFilter(row)
+	}
+	if e != nil {
+		eRead = &ReaderError{
+			T:    EReadFilter,
+			Func: "Read",
+			What: e.Error(),
+			Line: string(line),
+		}
+		return nil, eRead
+	}
+
+	if trailer := getTrailer(reader); trailer != nil {
+		cp.Sum += trailer.value(row)
+	}
+
+	if !isMatch {
+		return nil, nil
+	}
+
+	transformer, ok := reader.(TransformerReaderInterface)
+	if !ok {
+		return []*tabula.Row{row}, nil
+	}
+
+	rows, e = transformer.ApplyTransformers(row)
+	if e != nil {
+		eRead = &ReaderError{
+			T:    EReadTransform,
+			Func: "Read",
+			What: e.Error(),
+			Line: string(line),
+		}
+		return nil, eRead
+	}
+
+	return rows, nil
+}
+
 //
 // parsingLeftQuote parse the left-quote string from line.
 //
@@ -139,18 +325,23 @@ func parsingLeftQuote(lq, line []byte, startAt int) (
 //
 // Return the data and index of last parsed line, or error if separator is not
 // found or not match with specification.
+// The returned data is a sub-slice of line, with capacity limited to its
+// length, so appending to it will not modify the line.
 //
 func parsingSeparator(sep, line []byte, startAt int) (
 	v []byte, p int, eRead *ReaderError,
 ) {
 	p = startAt
 
-	v, p, found := tekstus.BytesCutUntil(line, sep, p, false)
-
-	if found {
-		return v, p, nil
+	x := bytes.Index(line[p:], sep)
+	if x >= 0 {
+		v = line[p : p+x : p+x]
+		return v, p + x + len(sep), nil
 	}
 
+	v = line[p:len(line):len(line)]
+	p = len(line)
+
 	eRead = &ReaderError{
 		Func: "parsingSeparator",
 		What: "Missing separator '" + string(sep) + "'",
@@ -162,13 +353,31 @@ func parsingSeparator(sep, line []byte, startAt int) (
 	return v, p, eRead
 }
 
+//
+// recordRewinder is implemented by reader that can set the input position
+// back to the second line of current record.
+//
+type recordRewinder interface {
+	rewindRecord()
+}
+
 //
 // parsingRightQuote parsing the line until we found the right quote or separator.
 //
 // Return the data and index of last parsed line, or error if right-quote is not
 // found or not match with specification.
 //
-func parsingRightQuote(reader ReaderInterface, rq, line []byte, startAt int) (
+// If the value is in one line and does not contain escape character, the
+// returned data is a sub-slice of line, otherwise its a new slice with the
+// escape character removed.
+//
+// If maxBytes is greater than zero, it will return error as soon as the value
+// is longer than maxBytes, without joining the next line, and the input
+// position is set back to the second line of record, like in FetchNextLine.
+//
+func parsingRightQuote(reader ReaderInterface, rq, line []byte, startAt,
+	maxBytes int,
+) (
 	v, lines []byte, p int, eRead *ReaderError,
 ) {
 	var e error
@@ -176,6 +385,13 @@ func parsingRightQuote(reader ReaderInterface, rq, line []byte, startAt int) (
 	p = startAt
 	var found bool
 
+	// Fast path, no escape character between start and right-quote.
+	x := bytes.Index(line[p:], rq)
+	if x >= 0 && bytes.IndexByte(line[p:p+x], '\\') < 0 {
+		v = line[p : p+x : p+x]
+		return v, line, p + x + len(rq), nil
+	}
+
 	// (2.2.1)
 	for {
 		content, p, found = tekstus.BytesCutUntil(line, rq, p, true)
@@ -186,6 +402,23 @@ func parsingRightQuote(reader ReaderInterface, rq, line []byte, startAt int) (
 			return v, line, p, nil
 		}
 
+		// Stop joining the next line if value is already too long.
+		if maxBytes > 0 && len(v) > maxBytes {
+			if r, ok := reader.(recordRewinder); ok {
+				r.rewindRecord()
+			}
+
+			return v, line, p, &ReaderError{
+				T:    EReadFieldTooLong,
+				Func: "parsingRightQuote",
+				What: fmt.Sprintf("Field length %d is more than %d bytes",
+					len(v), maxBytes),
+				Line: string(line),
+				Pos:  p,
+				N:    0,
+			}
+		}
+
 		// EOL before finding right-quote.
 		// Read and join with the next line.
 		line, e = reader.FetchNextLine(line)
@@ -204,8 +437,15 @@ func parsingRightQuote(reader ReaderInterface, rq, line []byte, startAt int) (
 		N:    0,
 	}
 
-	if e == io.EOF {
+	switch e {
+	case io.EOF:
 		eRead.T &= EReadEOF
+	case ErrRecordTooLong:
+		eRead.T = EReadRecordTooLong
+		eRead.What += ", " + e.Error()
+	case ErrLineTooLong:
+		eRead.T = EReadLineTooLong
+		eRead.What += ", " + e.Error()
 	}
 
 	return v, line, p, eRead
@@ -237,67 +477,206 @@ func parsingSkipSeparator(sep, line []byte, startAt int) (
 	return p, eRead
 }
 
+//
+// isSpaceAt return true and the size of character if the character at index
+// `p` in line is a white space, including Unicode white space.
+//
+func isSpaceAt(line []byte, p int) (bool, int) {
+	if line[p] < utf8.RuneSelf {
+		switch line[p] {
+		case ' ', '\t', '\n', '\v', '\f', '\r':
+			return true, 1
+		}
+		return false, 1
+	}
+
+	r, size := utf8.DecodeRune(line[p:])
+
+	return unicode.IsSpace(r), size
+}
+
 //
 // parsingSkipSpace skip all space starting from `startAt`.
 //
 func parsingSkipSpace(line []byte, startAt int) (p int) {
-	linelen := len(line)
-
-	for p = startAt; p < linelen; p++ {
-		if line[p] == ' ' || line[p] == '\t' || line[p] == '\n' ||
-			line[p] == '\r' {
-			continue
+	for p = startAt; p < len(line); {
+		isSpace, size := isSpaceAt(line, p)
+		if !isSpace {
+			break
 		}
-		break
+		p += size
 	}
 	return
 }
 
+//
+// parsingWhitespace parse the line until we found white space or end of line,
+// and skip all white spaces after it.
+//
+// Return the data, as sub-slice of line, and index of the next field.
+//
+func parsingWhitespace(line []byte, startAt int) (v []byte, p int) {
+	start := parsingSkipSpace(line, startAt)
+
+	for p = start; p < len(line); {
+		isSpace, size := isSpaceAt(line, p)
+		if isSpace {
+			break
+		}
+		p += size
+	}
+
+	v = line[start:p:p]
+
+	return v, parsingSkipSpace(line, p)
+}
+
 //
 // ParseLine parse a line containing records. The output is array of record
 // (or single row).
 //
+// The input metadata is not read directly from reader, but from their parse
+// plan, which is compiled once when reader is initialized.
+//
 // This is how the algorithm works
-// (1) create n slice of record, where n is number of column metadata
+// (1) create slice of record, with capacity equal to number of column that
+// will be saved
+// (1.p) If reader has pattern, match the line with pattern.
+// (1.l) If reader use logfmt preset, parse the line into "key=value" pairs.
 // (2) for each metadata
+// (2.r) If column take the rest of line, use the rest of line as value.
+// (2.l) If reader use logfmt preset, get the value of key with the same name
+//       as metadata.
+// (2.p) If reader has pattern, cut the value using named group in pattern.
+//       Optional group that is not matched is saved as empty string.
+// (2.f) If column is fixed width, cut the value by character position.
 // (2.0) Check if the next sequence matched with separator.
 // (2.0.1) If its match, create empty record
 // (2.1) If using left quote, skip until we found left-quote
 // (2.2) If using right quote, append byte to buffer until right-quote
 // 	(2.2.1) If using separator, skip until separator
-// (2.3) If using separator, append byte to buffer until separator
+// 	(2.2.2) If separator is white spaces, skip all white spaces
+// (2.w) If separator is white spaces, append byte to buffer until white
+//       space, and skip all white spaces after it.
+// (2.3) If using separator, append byte to buffer until separator, or until
+//       one of alternative separators
 // (2.4) else append all byte to buffer.
+// (2.5) Normalize, collapse white spaces, trim, and convert case of value.
+// (2.6) Convert number from metadata locale, by removing currency, grouping
+//       separators, and percent sign, and replacing the decimal separator.
 // (3) save buffer to record
 //
 func ParseLine(reader ReaderInterface, line []byte) (
 	prow *tabula.Row, eRead *ReaderError,
 ) {
+	return parseLine(reader, getParsePlan(reader), line, nil)
+}
+
+//
+// parseLine parse a line using parse plan `plan`.
+//
+// If sepMatches is not nil, the index of alternative separator that is
+// matched in each field is recorded in it.
+//
+func parseLine(reader ReaderInterface, plan *parsePlan, line []byte,
+	sepMatches []int,
+) (
+	prow *tabula.Row, eRead *ReaderError,
+) {
+	var sep int
 	p := 0
-	rIdx := 0
-	inputMd := reader.GetInputMetadata()
-	row := make(tabula.Row, 0)
+	row := make(tabula.Row, 0, plan.ncol)
+
+	// (1.p)
+	var match []int
+	if plan.err != nil {
+		return nil, &ReaderError{
+			T:    EReadPattern,
+			Func: "ParseLine",
+			What: plan.err.Error(),
+			Line: string(line),
+			Pos:  0,
+			N:    0,
+		}
+	}
+	if plan.re != nil {
+		match = plan.re.FindSubmatchIndex(line)
+		if match == nil {
+			return nil, &ReaderError{
+				T:    EReadPattern,
+				Func: "ParseLine",
+				What: "Line does not match with pattern",
+				Line: string(line),
+				Pos:  0,
+				N:    0,
+			}
+		}
+	}
+
+	// (1.l)
+	var pairs []logfmtPair
+	if plan.logfmt {
+		pairs = parsingLogfmt(line)
+	}
 
-	for _, md := range inputMd {
-		lq := md.GetLeftQuote()
-		rq := md.GetRightQuote()
-		sep := md.GetSeparator()
-		v := []byte{}
+	for x := range plan.fields {
+		f := &plan.fields[x]
+		var v []byte
 
-		// (2.0)
-		if sep != "" && sep != lq {
-			match := tekstus.BytesMatchForward(line, []byte(sep),
-				p)
+		// (2.r)
+		if f.remainder {
+			v = line[p:len(line):len(line)]
+			p = len(line)
+			goto value
+		}
+
+		// (2.l)
+		if plan.logfmt {
+			v = logfmtValue(pairs, f.md.GetName())
+			goto value
+		}
 
+		// (2.p)
+		if plan.re != nil {
+			if f.group < 0 || match[2*f.group] < 0 {
+				if !f.skip {
+					row = append(row, tabula.NewRecordString(""))
+				}
+				continue
+			}
+			start, end := match[2*f.group], match[2*f.group+1]
+			v = line[start:end:end]
+			p = end
+			goto value
+		}
+
+		// (2.f)
+		if f.width > 0 {
+			v, p = parsingFixedWidth(f, line, p)
+			goto value
+		}
+
+		// (2.0)
+		if len(f.seps) > 0 && !f.sepIsLq {
 			// (2.0.1)
-			if match {
-				p += len(sep)
+			if y := f.matchSeparator(line[p:]); y >= 0 {
+				if sepMatches != nil {
+					sepMatches[x] = y
+				}
+				p += len(f.seps[y])
+				goto empty
+			}
+		} else if len(f.sep) > 0 && !f.sepIsLq {
+			// (2.0.1)
+			if bytes.HasPrefix(line[p:], f.sep) {
+				p += len(f.sep)
 				goto empty
 			}
 		}
 
 		// (2.1)
-		if lq != "" {
-			p, eRead = parsingLeftQuote([]byte(lq), line, p)
+		if len(f.lq) > 0 {
+			p, eRead = parsingLeftQuote(f.lq, line, p)
 
 			if eRead != nil {
 				return
@@ -305,17 +684,29 @@ func ParseLine(reader ReaderInterface, line []byte) (
 		}
 
 		// (2.2)
-		if rq != "" {
-			v, line, p, eRead = parsingRightQuote(reader, []byte(rq),
-				line, p)
+		if len(f.rq) > 0 {
+			v, line, p, eRead = parsingRightQuote(reader, f.rq, line,
+				p, plan.maxFieldBytes)
 
 			if eRead != nil {
+				if eRead.T == EReadFieldTooLong {
+					eRead.What = "md " + f.md.GetName() + ": " +
+						eRead.What
+				}
 				return
 			}
 
-			if sep != "" {
-				p, eRead = parsingSkipSeparator([]byte(sep),
-					line, p)
+			if len(f.seps) > 0 {
+				p, sep, eRead = parsingSkipSeparators(f, line, p)
+
+				if eRead != nil {
+					return
+				}
+				if sepMatches != nil {
+					sepMatches[x] = sep
+				}
+			} else if len(f.sep) > 0 {
+				p, eRead = parsingSkipSeparator(f.sep, line, p)
 
 				if eRead != nil {
 					return
@@ -323,20 +714,34 @@ func ParseLine(reader ReaderInterface, line []byte) (
 
 				// Handle multi space if separator is a single
 				// space.
-				if sep == " " {
+				if f.sepIsSpace {
 					p = parsingSkipSpace(line, p)
 				}
+			} else if f.sepIsWhitespace {
+				// (2.2.2)
+				p = parsingSkipSpace(line, p)
 			}
 		} else {
-			if sep != "" {
+			if f.sepIsWhitespace {
+				// (2.w)
+				v, p = parsingWhitespace(line, p)
+			} else if len(f.seps) > 0 {
+				v, p, sep, eRead = parsingSeparators(f, line, p)
+
+				if eRead != nil {
+					return
+				}
+				if sepMatches != nil {
+					sepMatches[x] = sep
+				}
+			} else if len(f.sep) > 0 {
 				// Skip space at beginning if separator is a
 				// single space.
-				if sep == " " {
+				if f.sepIsSpace {
 					p = parsingSkipSpace(line, p)
 				}
 
-				v, p, eRead = parsingSeparator([]byte(sep),
-					line, p)
+				v, p, eRead = parsingSeparator(f.sep, line, p)
 
 				if eRead != nil {
 					return
@@ -344,24 +749,51 @@ func ParseLine(reader ReaderInterface, line []byte) (
 
 				// Handle multi space if separator is a single
 				// space.
-				if sep == " " {
+				if f.sepIsSpace {
 					p = parsingSkipSpace(line, p)
 				}
 			} else {
 				v = line[p:]
-				p = p + len(line)
+				p = len(line)
+			}
+		}
+
+	value:
+		if plan.maxFieldBytes > 0 && len(v) > plan.maxFieldBytes {
+			msg := fmt.Sprintf("md %s: Field length %d is more than %d bytes",
+				f.md.GetName(), len(v), plan.maxFieldBytes)
+
+			return nil, &ReaderError{
+				T:    EReadFieldTooLong,
+				Func: "ParseLine",
+				What: msg,
+				Line: string(line),
+				Pos:  p,
+				N:    0,
 			}
 		}
 
-		if md.GetSkip() {
+		if f.skip {
 			continue
 		}
+
+		// (2.5)
+		if f.transform {
+			v = transformValue(f, v)
+		}
+		// (2.6)
+		if f.isLocale {
+			v = parsingNumber(f, v)
+		}
 	empty:
-		r, e := tabula.NewRecordBy(string(v), md.GetType())
+		r, e := tabula.NewRecordBy(string(v), f.t)
 
+		if nil != e && plan.keepInvalid {
+			r, e = tabula.NewRecordString(string(v)), nil
+		}
 		if nil != e {
 			msg := fmt.Sprintf("md %s: Type convertion error from %q to %s",
-				md.GetName(), string(v), md.GetTypeName())
+				f.md.GetName(), string(v), f.md.GetTypeName())
 
 			return nil, &ReaderError{
 				T:    ETypeConversion,
@@ -374,7 +806,6 @@ func ParseLine(reader ReaderInterface, line []byte) (
 		}
 
 		row = append(row, r)
-		rIdx++
 	}
 
 	return &row, nil
@@ -384,16 +815,47 @@ func ParseLine(reader ReaderInterface, line []byte) (
 // ReadRow read one line at a time until we get one row or error when parsing the
 // data.
 //
+// Comment line is skipped and passed to reader PushComment. Empty line is
+// skipped if reader IsSkipBlankLines return true.
+//
+// If reader has layouts, the line is parsed using the metadata of layout that
+// match with the line.
+//
 func ReadRow(reader ReaderInterface, linenum int) (
 	row *tabula.Row,
 	line []byte,
 	n int,
 	eRead *ReaderError,
+) {
+	row, _, line, n, eRead = readRow(reader, linenum)
+	return
+}
+
+//
+// readRow read one row, and return the layout that is used to parse the row,
+// or nil if row is parsed using reader InputMetadata.
+//
+func readRow(reader ReaderInterface, linenum int) (
+	row *tabula.Row,
+	layout *Layout,
+	line []byte,
+	n int,
+	eRead *ReaderError,
 ) {
 	var e error
+	var plan *parsePlan
+	var sepMatches []int
+	var comment string
+
 	n = linenum
+	skipBlank := true
+	commenter, isCommenter := reader.(CommentReaderInterface)
+	if isCommenter {
+		comment = commenter.GetComment()
+		skipBlank = commenter.IsSkipBlankLines()
+	}
 
-	// Read one line, skip empty line.
+	// Read one line, skip comment and empty line.
 	for {
 		line, e = reader.ReadLine()
 		n++
@@ -402,33 +864,89 @@ func ReadRow(reader ReaderInterface, linenum int) (
 			goto err
 		}
 
-		// check for empty line
 		linetrimed := bytes.TrimSpace(line)
 
-		if len(linetrimed) > 0 {
+		if len(comment) > 0 && len(linetrimed) >= len(comment) &&
+			string(linetrimed[:len(comment)]) == comment {
+			commenter.PushComment(linetrimed)
+			continue
+		}
+
+		// check for empty line
+		if len(linetrimed) > 0 || !skipBlank {
 			break
 		}
 	}
 
+	if selector, ok := reader.(LayoutReaderInterface); ok {
+		layout, e = selector.SelectLayout(bytes.TrimSpace(line))
+	}
+	if e != nil {
+		eRead = &ReaderError{
+			T:    EReadLayout,
+			Func: "ReadRow",
+			What: fmt.Sprint(e),
+			Line: string(line),
+		}
+		return nil, nil, line, n, eRead
+	}
+
+	if layout != nil {
+		plan = layout.plan
+	} else {
+		plan = getParsePlan(reader)
+		if counter, ok := reader.(separatorCounter); ok {
+			sepMatches = counter.separatorMatches(len(plan.fields))
+		}
+	}
+
+	// Fixed width line is trimmed only at the right side, to keep the
+	// column position.
 	if reader.IsTrimSpace() {
-		line = bytes.TrimSpace(line)
+		if plan.isFixed {
+			line = bytes.TrimRight(line, " \t\r\n")
+		} else {
+			line = bytes.TrimSpace(line)
+		}
 	}
 
-	row, eRead = ParseLine(reader, line)
+	row, eRead = parseLine(reader, plan, line, sepMatches)
 
-	return row, line, n, eRead
+	return row, layout, line, n, eRead
 
 err:
+	eRead, ok := e.(*ReaderError)
+	if ok {
+		return nil, nil, line, n, eRead
+	}
+
 	eRead = &ReaderError{
 		Func: "ReadRow",
 		What: fmt.Sprint(e),
 	}
 
-	if e == io.EOF {
+	switch e {
+	case io.EOF:
 		eRead.T = EReadEOF
-	} else {
+	case ErrIdle:
+		eRead.T = EReadIdle
+	case ErrLineTooLong:
+		eRead.T = EReadLineTooLong
+	default:
 		eRead.T = EReadLine
 	}
 
-	return nil, line, n, eRead
+	return nil, nil, line, n, eRead
+}
+
+//
+// isRecordError return true if the error is caused by a record in input file,
+// not by the end of input or trailer.
+//
+func isRecordError(eRead *ReaderError) bool {
+	switch eRead.T {
+	case EReadIdle, EReadEOF, EReadTrailer:
+		return false
+	}
+	return true
 }
@@ -0,0 +1,180 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	// SeparatorMatchFirst define that the first alternative separator in
+	// list that match with the line will be used.
+	SeparatorMatchFirst = "first"
+	// SeparatorMatchLongest define that the longest alternative separator
+	// that match with the line will be used.
+	SeparatorMatchLongest = "longest"
+)
+
+//
+// matchSeparator return the index of alternative separator that match at the
+// beginning of `b`, or -1 if none of them is match.
+//
+func (f *fieldPlan) matchSeparator(b []byte) (idx int) {
+	idx = -1
+	for x, sep := range f.seps {
+		if !bytes.HasPrefix(b, sep) {
+			continue
+		}
+		if !f.sepLongest {
+			return x
+		}
+		if idx < 0 || len(sep) > len(f.seps[idx]) {
+			idx = x
+		}
+	}
+	return idx
+}
+
+//
+// missingSeparators return the error when none of alternative separators is
+// found in line.
+//
+func (f *fieldPlan) missingSeparators(fn string, line []byte, p int) (
+	eRead *ReaderError,
+) {
+	seps := make([]string, len(f.seps))
+	for x, sep := range f.seps {
+		seps[x] = "'" + string(sep) + "'"
+	}
+
+	return &ReaderError{
+		T:    EReadMissSeparator,
+		Func: fn,
+		What: "Missing separator " + strings.Join(seps, " or "),
+		Line: string(line),
+		Pos:  p,
+		N:    0,
+	}
+}
+
+//
+// parsingSeparators parsing the line until we found one of the alternative
+// separators.
+//
+// Return the data, as sub-slice of line, and index of the next field, or
+// error if none of separators is found.
+//
+func parsingSeparators(f *fieldPlan, line []byte, startAt int) (
+	v []byte, p, sep int, eRead *ReaderError,
+) {
+	for p = startAt; p < len(line); p++ {
+		sep = f.matchSeparator(line[p:])
+		if sep < 0 {
+			continue
+		}
+
+		return line[startAt:p:p], p + len(f.seps[sep]), sep, nil
+	}
+
+	v = line[startAt:len(line):len(line)]
+
+	return v, p, -1, f.missingSeparators("parsingSeparators", line, p)
+}
+
+//
+// parsingSkipSeparators skip the line until we found one of the alternative
+// separators.
+//
+// Return the index of the next field and the index of separator that is
+// found.
+//
+func parsingSkipSeparators(f *fieldPlan, line []byte, startAt int) (
+	p, sep int, eRead *ReaderError,
+) {
+	for p = startAt; p < len(line); p++ {
+		sep = f.matchSeparator(line[p:])
+		if sep < 0 {
+			continue
+		}
+
+		return p + len(f.seps[sep]), sep, nil
+	}
+
+	return p, -1, f.missingSeparators("parsingSkipSeparators", line, p)
+}
+
+//
+// separatorMatches return the list, with length `n`, where the index of
+// alternative separator that is matched in each field of the next parsed row
+// will be recorded.
+// All index in the list is reset to -1.
+//
+func (reader *Reader) separatorMatches(n int) []int {
+	if cap(reader.sepMatches) < n {
+		reader.sepMatches = make([]int, n)
+	}
+	reader.sepMatches = reader.sepMatches[:n]
+	for x := range reader.sepMatches {
+		reader.sepMatches[x] = -1
+	}
+	return reader.sepMatches
+}
+
+//
+// countSeparators add the alternative separators that is matched in the last
+// parsed row to the reader separator counts.
+//
+func (reader *Reader) countSeparators(plan *parsePlan) {
+	if len(reader.sepMatches) != len(plan.fields) {
+		return
+	}
+
+	for x := range plan.fields {
+		f := &plan.fields[x]
+		y := reader.sepMatches[x]
+		if y < 0 {
+			continue
+		}
+
+		if reader.sepCounts == nil {
+			reader.sepCounts = make(map[string]map[string]int)
+		}
+
+		name := f.md.GetName()
+		counts := reader.sepCounts[name]
+		if counts == nil {
+			counts = make(map[string]int, len(f.seps))
+			reader.sepCounts[name] = counts
+		}
+
+		counts[string(f.seps[y])]++
+	}
+}
+
+//
+// GetSeparatorCount return the number of times each alternative separator of
+// column `name` is matched in the rows that has been read by the last Read,
+// or nil if column does not have alternative separators.
+// The line that is rejected is not counted.
+//
+func (reader *Reader) GetSeparatorCount(name string) (counts map[string]int) {
+	plan := reader.parsePlan()
+
+	for x := range plan.fields {
+		f := &plan.fields[x]
+		if f.md.GetName() != name || len(f.seps) == 0 {
+			continue
+		}
+
+		counts = make(map[string]int, len(f.seps))
+		for _, sep := range f.seps {
+			counts[string(sep)] = reader.sepCounts[name][string(sep)]
+		}
+		return counts
+	}
+
+	return nil
+}
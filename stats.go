@@ -0,0 +1,83 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"fmt"
+	"time"
+)
+
+//
+// Stats contain the counters of input and rows that has been processed by
+// Reader, Writer, or ReadWriter.
+//
+type Stats struct {
+	// Bytes is the number of bytes that has been read from input file.
+	Bytes int64 `json:"Bytes"`
+	// Lines is the number of physical lines that has been read from
+	// input file.
+	Lines int `json:"Lines"`
+	// RowsRead is the number of rows that has been read and saved in
+	// dataset.
+	RowsRead int `json:"RowsRead"`
+	// RowsWritten is the number of rows that has been written to output.
+	RowsWritten int `json:"RowsWritten"`
+	// RowsFiltered is the number of rows that does not match with reader
+	// Filter or dropped by reader transformers.
+	RowsFiltered int `json:"RowsFiltered"`
+	// RowsRejected is the number of lines and rows that has been written
+	// to rejected file.
+	RowsRejected int `json:"RowsRejected"`
+	// Elapsed is the time since the input file is opened.
+	Elapsed time.Duration `json:"Elapsed"`
+}
+
+//
+// RowsPerSecond return the number of rows that has been read, filtered, or
+// rejected, per second.
+//
+func (stats Stats) RowsPerSecond() float64 {
+	if stats.Elapsed <= 0 {
+		return 0
+	}
+	n := stats.RowsRead + stats.RowsFiltered + stats.RowsRejected
+	return float64(n) / stats.Elapsed.Seconds()
+}
+
+//
+// BytesPerSecond return the number of bytes that has been read per second.
+//
+func (stats Stats) BytesPerSecond() float64 {
+	if stats.Elapsed <= 0 {
+		return 0
+	}
+	return float64(stats.Bytes) / stats.Elapsed.Seconds()
+}
+
+//
+// String return the counters and throughput in single line.
+//
+func (stats Stats) String() string {
+	return fmt.Sprintf("bytes=%d lines=%d read=%d written=%d filtered=%d"+
+		" rejected=%d elapsed=%s rows/s=%.1f bytes/s=%.1f",
+		stats.Bytes, stats.Lines, stats.RowsRead, stats.RowsWritten,
+		stats.RowsFiltered, stats.RowsRejected, stats.Elapsed,
+		stats.RowsPerSecond(), stats.BytesPerSecond())
+}
+
+//
+// since return the difference of counters between stats and `start`.
+//
+func (stats Stats) since(start Stats) Stats {
+	return Stats{
+		Bytes:        stats.Bytes - start.Bytes,
+		Lines:        stats.Lines - start.Lines,
+		RowsRead:     stats.RowsRead - start.RowsRead,
+		RowsWritten:  stats.RowsWritten - start.RowsWritten,
+		RowsFiltered: stats.RowsFiltered - start.RowsFiltered,
+		RowsRejected: stats.RowsRejected - start.RowsRejected,
+		Elapsed:      stats.Elapsed - start.Elapsed,
+	}
+}
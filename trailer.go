@@ -0,0 +1,335 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"fmt"
+	"github.com/shuLhan/tabula"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//
+// Trailer define the format of trailer line, a line in input file that
+// contain the number of records or the sum of column values, for example
+// "TRL|000123".
+//
+// The trailer line is not saved in dataset. When reader reach the end of
+// input file, the values in trailer line are compared with the records that
+// has been read, and Read will return error with type EReadTrailer if its not
+// match.
+//
+type Trailer struct {
+	// Pattern is the regular expression to match the trailer line, for
+	// example "^TRL\\|".
+	Pattern string `json:"Pattern"`
+	// Metadata define the format of each field in trailer line.
+	Metadata []Metadata `json:"Metadata"`
+	// Count is the name of field in trailer Metadata that contain the
+	// number of records in input file, including the rejected and
+	// filtered records.
+	// The records is counted before its transformed by reader
+	// Transformers.
+	Count string `json:"Count"`
+	// Sum is the name of field in trailer Metadata that contain the sum
+	// of SumColumn values.
+	Sum string `json:"Sum"`
+	// SumColumn is the name of input metadata where its value, in each
+	// row that has been read, will be summed and compared with Sum.
+	SumColumn string `json:"SumColumn"`
+
+	// re is the compiled Pattern.
+	re *regexp.Regexp
+	// plan is the compiled trailer Metadata.
+	plan *parsePlan
+	// countIdx is the index of Count field in trailer row.
+	countIdx int
+	// sumIdx is the index of Sum field in trailer row.
+	sumIdx int
+	// colIdx is the index of SumColumn in input row.
+	colIdx int
+	// row contain the parsed trailer line.
+	row *tabula.Row
+	// isVerified is true if trailer has been verified.
+	isVerified bool
+}
+
+//
+// footerLine contain the line that has been read ahead, to check whether its
+// one of the footer lines, or the line of record that will be read again.
+//
+type footerLine struct {
+	line []byte
+	e    error
+	// size is the number of bytes of line in input file, including EOL.
+	size int64
+	// nline is the number of physical line.
+	nline int
+}
+
+//
+// trailerIndex return the index of metadata `name` in row, or -1 if name is
+// empty.
+//
+func trailerIndex(mds []MetadataInterface, name string) (idx int, e error) {
+	if name == "" {
+		return -1, nil
+	}
+
+	for _, md := range mds {
+		if md.GetSkip() {
+			continue
+		}
+		if md.GetName() == name {
+			return idx, nil
+		}
+		idx++
+	}
+
+	return -1, fmt.Errorf("dsv: Trailer: unknown column %q", name)
+}
+
+//
+// trailerFloat convert the record value to float.
+//
+func trailerFloat(rec *tabula.Record) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(rec.String()), 64)
+}
+
+//
+// init compile the trailer pattern and metadata, and find the index of Count,
+// Sum, and SumColumn using input metadata `mds`.
+//
+func (trailer *Trailer) init(mds []MetadataInterface) (e error) {
+	trailer.re, e = regexp.Compile(trailer.Pattern)
+	if e != nil {
+		return e
+	}
+
+	tmds := make([]MetadataInterface, len(trailer.Metadata))
+	for x := range trailer.Metadata {
+		trailer.Metadata[x].Init()
+		tmds[x] = &trailer.Metadata[x]
+	}
+	trailer.plan = newParsePlan(tmds)
+
+	trailer.countIdx, e = trailerIndex(tmds, trailer.Count)
+	if e != nil {
+		return e
+	}
+	trailer.sumIdx, e = trailerIndex(tmds, trailer.Sum)
+	if e != nil {
+		return e
+	}
+	trailer.colIdx, e = trailerIndex(mds, trailer.SumColumn)
+	if e != nil {
+		return e
+	}
+
+	trailer.reset()
+
+	return nil
+}
+
+//
+// reset clear the parsed trailer line, so it can be read and verified again.
+//
+func (trailer *Trailer) reset() {
+	trailer.row = nil
+	trailer.isVerified = false
+}
+
+//
+// isMatch return true if line match with trailer Pattern.
+//
+func (trailer *Trailer) isMatch(line []byte) bool {
+	return trailer.re != nil && trailer.re.Match(line)
+}
+
+//
+// parse the trailer line using trailer Metadata.
+//
+func (trailer *Trailer) parse(reader ReaderInterface, line []byte) (
+	eRead *ReaderError,
+) {
+	trailer.row, eRead = parseLine(reader, trailer.plan, line, nil)
+	return eRead
+}
+
+//
+// value return the value of SumColumn in `row`.
+//
+func (trailer *Trailer) value(row *tabula.Row) float64 {
+	if trailer.colIdx < 0 || trailer.colIdx >= len(*row) {
+		return 0
+	}
+
+	f, e := trailerFloat((*row)[trailer.colIdx])
+	if e != nil {
+		return 0
+	}
+
+	return f
+}
+
+//
+// verify compare the values in trailer line with the number of records and
+// the sum of column in checkpoint `cp`.
+//
+// It will return io.EOF if trailer is match or has been verified before,
+// otherwise it will return ReaderError with type EReadTrailer.
+//
+func (trailer *Trailer) verify(cp *Checkpoint) error {
+	if trailer.isVerified {
+		return io.EOF
+	}
+	trailer.isVerified = true
+
+	eRead := &ReaderError{
+		T:    EReadTrailer,
+		Func: "verifyTrailer",
+	}
+
+	if trailer.row == nil {
+		eRead.What = "Missing trailer line"
+		return eRead
+	}
+
+	row := *trailer.row
+	eRead.Line = fmt.Sprint(row)
+
+	if trailer.countIdx >= 0 && trailer.countIdx < len(row) {
+		count, e := trailerFloat(row[trailer.countIdx])
+		if e != nil {
+			eRead.What = fmt.Sprintf("Invalid trailer count %q",
+				row[trailer.countIdx].String())
+			return eRead
+		}
+
+		n := cp.Records
+		if int(count) != n {
+			eRead.What = fmt.Sprintf("Trailer count %d does not match with number of records %d",
+				int(count), n)
+			return eRead
+		}
+	}
+
+	if trailer.sumIdx >= 0 && trailer.sumIdx < len(row) {
+		sum, e := trailerFloat(row[trailer.sumIdx])
+		if e != nil {
+			eRead.What = fmt.Sprintf("Invalid trailer sum %q",
+				row[trailer.sumIdx].String())
+			return eRead
+		}
+
+		diff := math.Abs(sum - cp.Sum)
+		if diff > 1e-9*math.Max(1, math.Abs(sum)) {
+			eRead.What = fmt.Sprintf("Trailer sum of %s %v does not match with %v",
+				trailer.SumColumn, sum, cp.Sum)
+			return eRead
+		}
+	}
+
+	return io.EOF
+}
+
+//
+// GetTrailer return the trailer definition of reader, or nil if reader does
+// not have trailer.
+//
+func (reader *Reader) GetTrailer() *Trailer {
+	return reader.Trailer
+}
+
+//
+// getTrailer return the trailer of reader, or nil if reader does not
+// implement TrailerReaderInterface.
+//
+func getTrailer(reader ReaderInterface) *Trailer {
+	if v, ok := reader.(TrailerReaderInterface); ok {
+		return v.GetTrailer()
+	}
+	return nil
+}
+
+//
+// readLineFooter read one line from input file, while keeping the last
+// SkipFooter lines unread.
+//
+// The lines are read ahead until there are more than SkipFooter lines in
+// queue, and then the first line in queue is returned. When the end of file
+// is reached, the lines in queue are the footer lines and it will return
+// io.EOF.
+//
+// The checkpoint only include the lines that has been returned, not the lines
+// in queue. When the end of file is reached, the footer lines are added to
+// the checkpoint.
+//
+// If reader has a trailer, the trailer line is parsed when its read, before
+// its queued, so the trailer line can be one of the footer lines. The size of
+// trailer line is added to the last line in queue, or to the checkpoint if
+// queue is empty.
+//
+func (reader *Reader) readLineFooter(notifyIdle bool) (line []byte, e error) {
+	for len(reader.footer) <= reader.SkipFooter {
+		cp := reader.checkpoint
+
+		line, e = reader.readLineInput(notifyIdle)
+		if e == io.EOF && len(line) > 0 {
+			// The last line without EOL.
+			e = nil
+		} else if e != nil && e != ErrLineTooLong {
+			if e == io.EOF {
+				for _, f := range reader.footer {
+					reader.checkpoint.Offset += f.size
+					reader.checkpoint.Line += f.nline
+				}
+				reader.footer = reader.footer[:0]
+			}
+			return nil, e
+		}
+
+		if reader.Trailer != nil && len(line) > 0 &&
+			reader.Trailer.isMatch(line) {
+			if n := len(reader.footer); n > 0 {
+				reader.footer[n-1].size += reader.checkpoint.Offset -
+					cp.Offset
+				reader.footer[n-1].nline += reader.checkpoint.Line -
+					cp.Line
+
+				reader.checkpoint.Offset = cp.Offset
+				reader.checkpoint.Line = cp.Line
+			}
+
+			eRead := reader.Trailer.parse(reader, line)
+			if eRead != nil {
+				return line, eRead
+			}
+			continue
+		}
+
+		reader.footer = append(reader.footer, footerLine{
+			line:  line,
+			e:     e,
+			size:  reader.checkpoint.Offset - cp.Offset,
+			nline: reader.checkpoint.Line - cp.Line,
+		})
+
+		reader.checkpoint.Offset = cp.Offset
+		reader.checkpoint.Line = cp.Line
+	}
+
+	f := reader.footer[0]
+	copy(reader.footer, reader.footer[1:])
+	reader.footer = reader.footer[:len(reader.footer)-1]
+
+	reader.checkpoint.Offset += f.size
+	reader.checkpoint.Line += f.nline
+
+	return f.line, f.e
+}
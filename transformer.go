@@ -0,0 +1,107 @@
+// Copyright 2015-2018, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsv
+
+import (
+	"github.com/shuLhan/tabula"
+)
+
+//
+// RowTransformer is the interface to modify the row after its parsed and
+// before its saved in dataset, or before its written to output.
+//
+// TransformRow receive the row and the metadata of each record in row, and
+// return zero or more rows.
+// The metadata of skipped columns is not included, so md[i] is the metadata
+// of record (*row)[i].
+// Returning empty rows will drop the row, and returning more than one rows
+// will split the row.
+// Returning an error will reject the row.
+//
+type RowTransformer interface {
+	TransformRow(row *tabula.Row, md []MetadataInterface) (
+		[]*tabula.Row, error,
+	)
+}
+
+//
+// RowTransformerFunc is an adapter to use ordinary function as
+// RowTransformer.
+//
+type RowTransformerFunc func(row *tabula.Row, md []MetadataInterface) (
+	[]*tabula.Row, error,
+)
+
+//
+// TransformRow call fn(row, md).
+//
+func (fn RowTransformerFunc) TransformRow(row *tabula.Row,
+	md []MetadataInterface,
+) (
+	[]*tabula.Row, error,
+) {
+	return fn(row, md)
+}
+
+//
+// RowTransformers is a chain of row transformers, where the rows that is
+// returned by one transformer is passed to the next transformer.
+//
+type RowTransformers []RowTransformer
+
+//
+// TransformRow pass the row to each transformer in chain, and return the rows
+// from the last transformer.
+// If one of transformer return an error, the chain is stopped and the error
+// is returned.
+//
+func (chain RowTransformers) TransformRow(row *tabula.Row,
+	md []MetadataInterface,
+) (
+	rows []*tabula.Row, e error,
+) {
+	rows = []*tabula.Row{row}
+
+	for _, t := range chain {
+		var next []*tabula.Row
+
+		for _, r := range rows {
+			out, e := t.TransformRow(r, md)
+			if e != nil {
+				return nil, e
+			}
+			next = append(next, out...)
+		}
+
+		rows = next
+		if len(rows) == 0 {
+			break
+		}
+	}
+
+	return rows, nil
+}
+
+//
+// AddTransformer append the row transformer to the end of reader
+// Transformers.
+//
+func (reader *Reader) AddTransformer(t RowTransformer) {
+	reader.Transformers = append(reader.Transformers, t)
+}
+
+//
+// ApplyTransformers pass the row, that is parsed using reader InputMetadata,
+// to reader Transformers and return the result.
+// If reader does not have transformers, it will return the row itself.
+//
+func (reader *Reader) ApplyTransformers(row *tabula.Row) (
+	[]*tabula.Row, error,
+) {
+	if len(reader.Transformers) == 0 {
+		return []*tabula.Row{row}, nil
+	}
+	return reader.Transformers.TransformRow(row, reader.parsePlan().rowMds)
+}
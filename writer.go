@@ -7,10 +7,11 @@ package dsv
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"github.com/shuLhan/tabula"
 	"github.com/shuLhan/tekstus"
-	"log"
 	"os"
+	"sync/atomic"
 )
 
 const (
@@ -33,6 +34,29 @@ type Writer struct {
 	Output string `json:"Output"`
 	// OutputMetadata define format for each column.
 	OutputMetadata []Metadata `json:"OutputMetadata"`
+	// Header if its true, the name of each column in OutputMetadata is
+	// written as the first line of output, using the same quotes and
+	// separators as the records. Like in records, column that does not
+	// have input column, or its input column is skipped, is not written.
+	// The header is not written when appending to output that is not
+	// empty.
+	Header bool `json:"Header"`
+	// HeaderNames define the name of column in header, by their index in
+	// OutputMetadata, to replace the metadata name. Empty name will use
+	// the metadata name.
+	HeaderNames []string `json:"HeaderNames"`
+	// Logger define the logger for writer events, like loading config,
+	// and opening and closing output file.
+	// Default is nil, only the warning and error events is printed,
+	// to standard error.
+	Logger Logger `json:"-"`
+	// isEmpty is true if nothing has been written to output file.
+	isEmpty bool
+	// exprs contain the compiled Expr of each output metadata.
+	exprs []*expr
+	// rowsWritten is the number of rows that has been written to output,
+	// accessed atomically.
+	rowsWritten int64
 	// fWriter as write descriptor.
 	fWriter *os.File
 	// BufWriter for buffered writer.
@@ -88,6 +112,17 @@ func (writer *Writer) AddMetadata(md Metadata) {
 // open a generic method to open output file with specific flag.
 //
 func (writer *Writer) open(file string, flag int) (e error) {
+	mds := make([]MetadataInterface, len(writer.OutputMetadata))
+	for x := range writer.OutputMetadata {
+		writer.OutputMetadata[x].Init()
+		mds[x] = &writer.OutputMetadata[x]
+	}
+
+	e = checkMetadata(mds)
+	if e != nil {
+		return e
+	}
+
 	if file == "" {
 		if writer.Output == "" {
 			file = DefOutput
@@ -101,8 +136,18 @@ func (writer *Writer) open(file string, flag int) (e error) {
 		return e
 	}
 
+	fi, e := writer.fWriter.Stat()
+	if nil != e {
+		return e
+	}
+
+	writer.isEmpty = fi.Size() == 0
+
 	writer.BufWriter = bufio.NewWriter(writer.fWriter)
 
+	writer.GetLogger().Info("output opened", "path", file,
+		"append", flag&os.O_APPEND != 0)
+
 	return nil
 }
 
@@ -136,6 +181,10 @@ func (writer *Writer) Flush() error {
 //
 func (writer *Writer) Close() (e error) {
 	if nil != writer.BufWriter {
+		e = writer.writeHeader(nil)
+		if e != nil {
+			return
+		}
 		e = writer.BufWriter.Flush()
 		if e != nil {
 			return
@@ -143,79 +192,257 @@ func (writer *Writer) Close() (e error) {
 	}
 	if nil != writer.fWriter {
 		e = writer.fWriter.Close()
+		if e != nil {
+			return
+		}
+
+		writer.GetLogger().Info("output closed",
+			"path", writer.fWriter.Name(),
+			"rows", writer.GetRowsWritten())
 	}
 	return
 }
 
+//
+// compileExprs compile the Expr in each output metadata, if its not compiled
+// yet or has been changed since the last compilation.
+//
+func (writer *Writer) compileExprs() (e error) {
+	if len(writer.exprs) != len(writer.OutputMetadata) {
+		writer.exprs = make([]*expr, len(writer.OutputMetadata))
+	}
+
+	for x := range writer.OutputMetadata {
+		src := writer.OutputMetadata[x].GetExpr()
+		if src == "" {
+			writer.exprs[x] = nil
+			continue
+		}
+		if writer.exprs[x] != nil && writer.exprs[x].src == src {
+			continue
+		}
+
+		writer.exprs[x], e = compileExpr(src)
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+//
+// writeLine write one row to output and increase the number of rows written.
+//
+func (writer *Writer) writeLine(v []byte) (e error) {
+	_, e = writer.BufWriter.Write(v)
+	if e == nil {
+		atomic.AddInt64(&writer.rowsWritten, 1)
+	}
+	return e
+}
+
+//
+// GetRowsWritten return the number of rows that has been written to output,
+// excluding the header. Its safe to be called from other goroutine while
+// writing.
+//
+func (writer *Writer) GetRowsWritten() int {
+	return int(atomic.LoadInt64(&writer.rowsWritten))
+}
+
+//
+// writeHeader write the header line if Header is true and nothing has been
+// written to output file.
+// If record metadata `recordMd` is not nil, the column that is not written by
+// WriteRow, because its input column is not found or ignored, is not written
+// in header.
+//
+func (writer *Writer) writeHeader(recordMd []MetadataInterface) (e error) {
+	if !writer.isEmpty {
+		return nil
+	}
+
+	writer.isEmpty = false
+
+	if !writer.Header {
+		return nil
+	}
+
+	v := []byte{}
+
+	for x := range writer.OutputMetadata {
+		md := &writer.OutputMetadata[x]
+
+		// Skip the column that is not written by WriteRow.
+		if recordMd != nil && md.GetExpr() == "" && md.GetValue() == "" {
+			_, ok := inputIndex(md, recordMd, len(recordMd))
+			if !ok {
+				continue
+			}
+		}
+
+		name := md.GetName()
+		if x < len(writer.HeaderNames) && writer.HeaderNames[x] != "" {
+			name = writer.HeaderNames[x]
+		}
+
+		v = appendField(v, []byte(name), md, true)
+	}
+
+	v = append(v, DefEOL)
+
+	_, e = writer.BufWriter.Write(v)
+
+	return e
+}
+
+//
+// inputIndex return the index of input record that is written to output
+// column `md`, by matching the output name, or From, with record metadata.
+// It will return false if no input metadata is matched, or the input column
+// is ignored, and the output column should not be written.
+//
+func inputIndex(md *Metadata, recordMd []MetadataInterface,
+	nRecord int,
+) (
+	rIdx int, ok bool,
+) {
+	rIdx, mdMatch := FindMetadata(&Metadata{Name: md.GetFrom()}, recordMd)
+
+	// No input metadata matched? skip it too.
+	if rIdx >= nRecord {
+		return rIdx, false
+	}
+
+	// If input column is ignored, continue to next record.
+	if mdMatch != nil && mdMatch.GetSkip() {
+		return rIdx, false
+	}
+
+	return rIdx, true
+}
+
 //
 // WriteRow dump content of Row to file using format in metadata.
 //
 func (writer *Writer) WriteRow(row *tabula.Row, recordMd []MetadataInterface) (
 	e error,
 ) {
+	e = writer.writeHeader(recordMd)
+	if e != nil {
+		return e
+	}
+
+	e = writer.compileExprs()
+	if e != nil {
+		return e
+	}
+
 	nRecord := row.Len()
 	v := []byte{}
-	esc := []byte(DefEscape)
 
 	for i := range writer.OutputMetadata {
 		md := writer.OutputMetadata[i]
 
-		// find the input index based on name on record metadata.
-		rIdx, mdMatch := FindMetadata(&md, recordMd)
+		var rec *tabula.Record
 
-		// No input metadata matched? skip it too.
-		if rIdx >= nRecord {
-			continue
-		}
+		switch {
+		case writer.exprs[i] != nil:
+			x, e := writer.exprs[i].evalRow(row, recordMd)
+			if e != nil {
+				return e
+			}
+			rec = valueRecord(x)
+
+		case md.GetValue() != "":
+			rec, e = tabula.NewRecordBy(md.GetValue(), md.GetType())
+			if e != nil {
+				return fmt.Errorf("dsv: md %s: Type convertion"+
+					" error from %q to %s", md.GetName(),
+					md.GetValue(), md.GetTypeName())
+			}
 
-		// If input column is ignored, continue to next record.
-		if mdMatch != nil && mdMatch.GetSkip() {
-			continue
+		default:
+			rIdx, ok := inputIndex(&md, recordMd, nRecord)
+			if !ok {
+				continue
+			}
+
+			rec = (*row)[rIdx]
 		}
 
-		recV := (*row)[rIdx].Bytes()
-		lq := md.GetLeftQuote()
+		recV := formatRecord(rec, &md)
 
-		if "" != lq {
-			v = append(v, []byte(lq)...)
-		}
+		v = appendField(v, recV, &md, md.T == tabula.TString)
+	}
+
+	v = append(v, DefEOL)
 
-		rq := md.GetRightQuote()
-		sep := md.GetSeparator()
+	e = writer.writeLine(v)
 
-		// Escape the escape character itself.
-		if md.T == tabula.TString {
-			recV, _ = tekstus.BytesEncapsulate(esc, recV, esc, nil)
-		}
+	return e
+}
 
-		// Escape the right quote in field content before writing it.
-		if "" != rq && md.T == tabula.TString {
-			recV, _ = tekstus.BytesEncapsulate([]byte(rq), recV,
-				esc, nil)
-		} else {
-			// Escape the separator
-			if "" != sep && md.T == tabula.TString {
-				recV, _ = tekstus.BytesEncapsulate([]byte(sep),
-					recV, esc, nil)
-			}
-		}
+//
+// appendField append the value of one column to `v` using the separator,
+// quotes, and width in metadata.
+// If value is string, the escape character, right quote, and separator in
+// value are escaped.
+//
+func appendField(v, recV []byte, md *Metadata, isString bool) []byte {
+	esc := []byte(DefEscape)
 
-		v = append(v, recV...)
+	// White spaces separator is written as single space.
+	sep := md.GetSeparator()
+	if md.GetSeparatorMode() == SeparatorModeWhitespace {
+		sep = " "
+	}
+
+	lq := md.GetLeftQuote()
+	rq := md.GetRightQuote()
 
-		if "" != rq {
-			v = append(v, []byte(rq)...)
+	// Fixed width column, without separator and quotes, is not quoted
+	// nor escaped. Column with width that has separator or quotes is
+	// padded and then written as delimited column.
+	if md.GetWidth() > 0 {
+		if "" == sep && "" == lq && "" == rq {
+			return appendFixedWidth(v, recV, md)
 		}
+		recV = padWidth(recV, md)
+	}
+
+	if "" != lq {
+		v = append(v, []byte(lq)...)
+	}
 
-		if "" != sep {
-			v = append(v, []byte(sep)...)
+	// Escape the escape character itself.
+	if isString {
+		recV, _ = tekstus.BytesEncapsulate(esc, recV, esc, nil)
+	}
+
+	// Escape the right quote in field content before writing it.
+	if "" != rq && isString {
+		recV, _ = tekstus.BytesEncapsulate([]byte(rq), recV, esc, nil)
+	} else {
+		// Escape the separator
+		if "" != sep && isString {
+			recV, _ = tekstus.BytesEncapsulate([]byte(sep),
+				recV, esc, nil)
 		}
 	}
 
-	v = append(v, DefEOL)
+	v = append(v, recV...)
 
-	_, e = writer.BufWriter.Write(v)
+	if "" != rq {
+		v = append(v, []byte(rq)...)
+	}
 
-	return e
+	if "" != sep {
+		v = append(v, []byte(sep)...)
+	}
+
+	return v
 }
 
 //
@@ -227,11 +454,12 @@ func (writer *Writer) WriteRows(rows tabula.Rows, recordMd []MetadataInterface)
 	n int,
 	e error,
 ) {
-	for n = range rows {
-		e = writer.WriteRow(rows[n], recordMd)
+	for _, row := range rows {
+		e = writer.WriteRow(row, recordMd)
 		if nil != e {
 			break
 		}
+		n++
 	}
 
 	_ = writer.Flush()
@@ -322,6 +550,11 @@ func (writer *Writer) WriteRawRow(row *tabula.Row, sep, esc []byte) (e error) {
 		esc = []byte(DefEscape)
 	}
 
+	e = writer.writeHeader(nil)
+	if e != nil {
+		return e
+	}
+
 	v := []byte{}
 	for x, rec := range *row {
 		if x > 0 {
@@ -339,7 +572,7 @@ func (writer *Writer) WriteRawRow(row *tabula.Row, sep, esc []byte) (e error) {
 
 	v = append(v, DefEOL)
 
-	_, e = writer.BufWriter.Write(v)
+	e = writer.writeLine(v)
 
 	_ = writer.Flush()
 
@@ -400,6 +633,11 @@ func (writer *Writer) WriteRawColumns(cols *tabula.Columns, sep *string) (
 		*sep = DefSeparator
 	}
 
+	e = writer.writeHeader(nil)
+	if e != nil {
+		return
+	}
+
 	// Find minimum and maximum column length.
 	minlen, maxlen := cols.GetMinMaxLength()
 
@@ -412,7 +650,7 @@ func (writer *Writer) WriteRawColumns(cols *tabula.Columns, sep *string) (
 		v := cols.Join(x, sepbytes, esc)
 		v = append(v, DefEOL)
 
-		_, e = writer.BufWriter.Write(v)
+		e = writer.writeLine(v)
 
 		if nil != e {
 			return x, e
@@ -424,7 +662,7 @@ func (writer *Writer) WriteRawColumns(cols *tabula.Columns, sep *string) (
 		v := cols.Join(x, sepbytes, esc)
 		v = append(v, DefEOL)
 
-		_, e = writer.BufWriter.Write(v)
+		e = writer.writeLine(v)
 
 		if nil != e {
 			break
@@ -509,7 +747,7 @@ func (writer *Writer) String() string {
 	r, e := json.MarshalIndent(writer, "", "\t")
 
 	if nil != e {
-		log.Print(e)
+		writer.GetLogger().Error("marshal writer failed", "error", e)
 	}
 
 	return string(r)
@@ -5,6 +5,11 @@
 package dsv_test
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/shuLhan/dsv"
@@ -125,3 +130,677 @@ func TestWriteRawColumns(t *testing.T) {
 
 	assertFile(t, outfile, expfile, true)
 }
+
+//
+// TestWriterFixedWidth test converting fixed width input to delimited output
+// and then back to fixed width output.
+//
+func TestWriterFixedWidth(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"001Alice     00012.50\n002Bob       00003.00\n"+
+			"000Zero      -0000.50\n")
+
+	reader := &dsv.Reader{
+		Input:     input,
+		Rejected:  filepath.Join(dir, "rejected.dat"),
+		MaxRows:   -1,
+		TrimSpace: true,
+		InputMetadata: []dsv.Metadata{{
+			Name:  "id",
+			Type:  "integer",
+			Width: 3,
+			Pad:   "0",
+			Align: dsv.AlignRight,
+		}, {
+			Name:  "name",
+			Width: 10,
+		}, {
+			Name:  "amount",
+			Type:  "real",
+			Start: 14,
+			End:   21,
+			Pad:   "0",
+			Align: dsv.AlignRight,
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[1 Alice 12.5]&[2 Bob 3]&[0 Zero -0.5]")
+
+	cases := []struct {
+		desc string
+		mds  []dsv.Metadata
+		exp  string
+	}{{
+		desc: "To delimited",
+		mds: []dsv.Metadata{{
+			Name:      "id",
+			Separator: ",",
+		}, {
+			Name:      "name",
+			Separator: ",",
+		}, {
+			Name: "amount",
+		}},
+		exp: "1,Alice,12.5\n2,Bob,3\n0,Zero,-0.5\n",
+	}, {
+		desc: "To fixed width",
+		mds: []dsv.Metadata{{
+			Name:  "id",
+			Width: 5,
+			Pad:   "0",
+			Align: dsv.AlignRight,
+		}, {
+			Name:  "name",
+			Width: 4,
+		}, {
+			Name:  "amount",
+			Start: 12,
+			Width: 6,
+			Align: dsv.AlignRight,
+		}},
+		exp: "00001Alic    12.5\n00002Bob        3\n" +
+			"00000Zero    -0.5\n",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		output := filepath.Join(dir, "output.dat")
+
+		writer := &dsv.Writer{
+			OutputMetadata: c.mds,
+		}
+
+		e = writer.OpenOutput(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = writer.Write(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		e = writer.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		got, e := ioutil.ReadFile(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, c.exp, string(got), true)
+	}
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Pad must be a single character.
+	writer := &dsv.Writer{
+		OutputMetadata: []dsv.Metadata{{
+			Name:  "id",
+			Width: 5,
+			Pad:   "00",
+		}},
+	}
+
+	e = writer.OpenOutput(filepath.Join(dir, "output.dat"))
+
+	assert(t, `dsv: md id: Pad "00" must be a single character`,
+		fmt.Sprint(e), true)
+}
+
+func TestWriterLocale(t *testing.T) {
+	lines := "1.234.567,89;€ 12,50;12,5 %;1'234\n" +
+		"-1.000,5;3 €;25%;1 000\n"
+
+	dir, input := writeTempFile(t, "input.dat", lines)
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		MaxRows:  -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:              "amount",
+			Type:              "real",
+			Separator:         ";",
+			DecimalSeparator:  ",",
+			GroupingSeparator: ".",
+		}, {
+			Name:             "price",
+			Type:             "real",
+			Separator:        ";",
+			DecimalSeparator: ",",
+			Currency:         "€",
+		}, {
+			Name:             "rate",
+			Type:             "real",
+			Separator:        ";",
+			DecimalSeparator: ",",
+			Percent:          true,
+		}, {
+			Name:              "count",
+			Type:              "integer",
+			GroupingSeparator: "' ",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	checkDataset(t, reader, "&[1234567.89 12.5 0.125 1234]&[-1000.5 3 0.25 1000]")
+
+	output := filepath.Join(dir, "output.dat")
+
+	writer := &dsv.Writer{
+		OutputMetadata: []dsv.Metadata{{
+			Name:              "amount",
+			Separator:         "\t",
+			GroupingSeparator: ",",
+		}, {
+			Name:           "price",
+			Separator:      "\t",
+			Currency:       " EUR",
+			CurrencySuffix: true,
+		}, {
+			Name:      "rate",
+			Separator: "\t",
+			Percent:   true,
+		}, {
+			Name:              "count",
+			GroupingSeparator: " ",
+		}},
+	}
+
+	e = writer.OpenOutput(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = writer.Write(reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = writer.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	got, e := ioutil.ReadFile(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	exp := "1,234,567.89\t12.5 EUR\t12.5%\t1 234\n" +
+		"-1,000.5\t3 EUR\t25%\t1 000\n"
+
+	assert(t, exp, string(got), true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Percent can not be used in integer input column.
+	reader = &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		InputMetadata: []dsv.Metadata{{
+			Name:    "rate",
+			Type:    "integer",
+			Percent: true,
+		}},
+	}
+
+	e = reader.Init("", nil)
+
+	assert(t, "dsv: md rate: Percent can not be used in integer column",
+		e.Error(), true)
+}
+
+func TestWriterFormat(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"1,alice,12.5\n-2,bob smith,3\n")
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		MaxRows:  -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name:      "name",
+			Separator: ",",
+		}, {
+			Name: "amount",
+			Type: "real",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	cases := []struct {
+		desc string
+		mds  []dsv.Metadata
+		exp  string
+	}{{
+		desc: "With printf format",
+		mds: []dsv.Metadata{{
+			Name:      "id",
+			Separator: ",",
+			Format:    "%03d",
+		}, {
+			Name:      "name",
+			Separator: ",",
+			Case:      dsv.CaseUpper,
+			MaxLength: 3,
+		}, {
+			Name:   "amount",
+			Format: "%.2f",
+		}},
+		exp: "001,ALI,12.50\n-02,BOB,3.00\n",
+	}, {
+		desc: "With decimals and zero padding",
+		mds: []dsv.Metadata{{
+			Name:      "id",
+			Separator: ",",
+			ZeroPad:   4,
+		}, {
+			Name:      "name",
+			Separator: ",",
+			Case:      dsv.CaseTitle,
+		}, {
+			Name:     "amount",
+			Decimals: 2,
+		}},
+		exp: "0001,Alice,12.50\n-002,Bob Smith,3.00\n",
+	}, {
+		desc: "With width and alignment",
+		mds: []dsv.Metadata{{
+			Name:  "id",
+			Width: 3,
+			Align: dsv.AlignRight,
+		}, {
+			Name:  "name",
+			Width: 6,
+		}, {
+			Name:     "amount",
+			Width:    7,
+			Align:    dsv.AlignRight,
+			Decimals: 1,
+		}},
+		exp: "  1alice    12.5\n -2bob sm    3.0\n",
+	}, {
+		desc: "With width in delimited column",
+		mds: []dsv.Metadata{{
+			Name:      "name",
+			Width:     10,
+			Pad:       ".",
+			Separator: " ",
+		}, {
+			Name: "amount",
+		}},
+		exp: "alice..... 12.5\nbob\\ smith. 3\n",
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		output := filepath.Join(dir, "output.dat")
+
+		writer := &dsv.Writer{
+			OutputMetadata: c.mds,
+		}
+
+		e = writer.OpenOutput(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = writer.Write(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		e = writer.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		got, e := ioutil.ReadFile(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, c.exp, string(got), true)
+	}
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestWriterHeader(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat", "1,alice\n2,bob\n")
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		MaxRows:  -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name: "name",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	output := filepath.Join(dir, "output.dat")
+
+	writer := &dsv.Writer{
+		Header:      true,
+		HeaderNames: []string{"ID"},
+		OutputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Separator: ";",
+		}, {
+			// Column without input is not written in header
+			// and rows.
+			Name:      "missing",
+			Separator: ";",
+		}, {
+			Name:       "name",
+			LeftQuote:  "'",
+			RightQuote: "'",
+		}},
+	}
+
+	e = writer.OpenOutput(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Write the same rows twice, and once more after reopening the output.
+	for x := 0; x < 3; x++ {
+		if x == 2 {
+			e = writer.ReopenOutput(output)
+			if e != nil {
+				t.Fatal(e)
+			}
+		}
+
+		_, e = writer.Write(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	e = writer.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	got, e := ioutil.ReadFile(output)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	exp := "ID;'name'\n" + strings.Repeat("1;'alice'\n2;'bob'\n", 3)
+
+	assert(t, exp, string(got), true)
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestWriterExpr(t *testing.T) {
+	dir, input := writeTempFile(t, "input.dat",
+		"1,John,Doe,12.5,3,2018-03-04\n"+
+			"2,Jane,Roe,2,4,2019-12-31\n")
+
+	reader := &dsv.Reader{
+		Input:    input,
+		Rejected: filepath.Join(dir, "rejected.dat"),
+		MaxRows:  -1,
+		InputMetadata: []dsv.Metadata{{
+			Name:      "id",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name:      "first",
+			Separator: ",",
+		}, {
+			Name:      "last",
+			Separator: ",",
+		}, {
+			Name:      "price",
+			Type:      "real",
+			Separator: ",",
+		}, {
+			Name:      "qty",
+			Type:      "integer",
+			Separator: ",",
+		}, {
+			Name: "date",
+		}},
+	}
+
+	e := reader.Init("", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = dsv.Read(reader)
+	if e != io.EOF {
+		t.Fatal(e)
+	}
+
+	cases := []struct {
+		desc   string
+		mds    []dsv.Metadata
+		exp    string
+		expErr string
+	}{{
+		desc: "With From and Value",
+		mds: []dsv.Metadata{{
+			Name:      "customer_id",
+			From:      "id",
+			Separator: ",",
+		}, {
+			Name:      "version",
+			Value:     "v1",
+			Separator: ",",
+		}, {
+			Name: "last",
+		}},
+		exp: "1,v1,Doe\n2,v1,Roe\n",
+	}, {
+		desc: "With typed Value and Format",
+		mds: []dsv.Metadata{{
+			Name:      "seq",
+			Type:      "integer",
+			Value:     "1",
+			Format:    "%03d",
+			Separator: ",",
+		}, {
+			Name: "id",
+		}},
+		exp: "001,1\n001,2\n",
+	}, {
+		desc: "With invalid typed Value",
+		mds: []dsv.Metadata{{
+			Name:  "seq",
+			Type:  "integer",
+			Value: "x",
+		}},
+		expErr: `dsv: md seq: Type convertion error from "x" to integer`,
+	}, {
+		desc: "With concatenation and function",
+		mds: []dsv.Metadata{{
+			Name:      "name",
+			Expr:      `first + " " + upper(last)`,
+			Separator: ",",
+		}, {
+			Name: "year",
+			Expr: "int(substr(date, 0, 4)) + 1",
+		}},
+		exp: "John DOE,2019\nJane ROE,2020\n",
+	}, {
+		desc: "With substr length larger than value",
+		mds: []dsv.Metadata{{
+			Name: "last",
+			Expr: "substr(last, 1, 9223372036854775807)",
+		}},
+		exp: "oe\noe\n",
+	}, {
+		desc: "With arithmetic",
+		mds: []dsv.Metadata{{
+			Name:      "total",
+			Expr:      "price * qty",
+			Separator: ",",
+			Format:    "%.2f",
+		}, {
+			Name:      "half",
+			Expr:      "qty / 2",
+			Separator: ",",
+		}, {
+			Name: "big",
+			Expr: "price * qty >= 10 && !(id == 2)",
+		}},
+		exp: "37.50,1.5,true\n8.00,2,false\n",
+	}, {
+		desc: "With invalid expression",
+		mds: []dsv.Metadata{{
+			Name: "total",
+			Expr: "price *",
+		}},
+		expErr: `dsv: Invalid expression "price *": unexpected end`,
+	}, {
+		desc: "With unknown column",
+		mds: []dsv.Metadata{{
+			Name: "total",
+			Expr: "price * discount",
+		}},
+		expErr: `dsv: Expression "price * discount": unknown column "discount"`,
+	}}
+
+	for _, c := range cases {
+		t.Log(c.desc)
+
+		output := filepath.Join(dir, "output.dat")
+
+		writer := &dsv.Writer{
+			OutputMetadata: c.mds,
+		}
+
+		e = writer.OpenOutput(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = writer.Write(reader)
+		if e != nil {
+			assert(t, c.expErr, e.Error(), true)
+			_ = writer.Close()
+			continue
+		}
+
+		e = writer.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		got, e := ioutil.ReadFile(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, c.exp, string(got), true)
+	}
+
+	// Changing Expr after writing is applied on the next write.
+	output := filepath.Join(dir, "output.dat")
+	writer := &dsv.Writer{
+		OutputMetadata: []dsv.Metadata{{
+			Name: "qty",
+			Expr: "qty",
+		}},
+	}
+
+	for _, exp := range []string{"3\n4\n", "6\n8\n"} {
+		e = writer.OpenOutput(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		_, e = writer.Write(reader)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		e = writer.Close()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		got, e := ioutil.ReadFile(output)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert(t, exp, string(got), true)
+
+		writer.OutputMetadata[0].Expr = "qty * 2"
+	}
+
+	e = reader.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+}
@@ -25,6 +25,9 @@ func OpenWriter(writer WriterInterface, fcfg string) (e error) {
 		return
 	}
 
+	getLogger(writer).Debug("config loaded", "config", fcfg,
+		"output", writer.GetOutput())
+
 	return InitWriter(writer)
 }
 